@@ -0,0 +1,253 @@
+package gisp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+var (
+	typeContext = reflect.TypeOf((*Context)(nil))
+	typeGoCtx   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeDict    = reflect.TypeOf((*Dict)(nil))
+)
+
+// Bind wraps a Go function into a sandbox function, such as
+// func(a, b float64) (float64, error) or func(ctx context.Context, id string) bool.
+// The first parameter can be a *Context to get the current context, or a context.Context
+// which is the ENV if the ENV implements context.Context, or context.Background().
+// The script arguments are converted to the types of the parameters, the missing ones
+// are zero values, the variadic parameter takes the rest of the arguments.
+// The function can return nothing, a value, an error, or a value and an error,
+// the error stops the run as an Error.
+// It panics if the fn is not a function or the results are not supported.
+// The funcs put into the Sandbox are bound automatically, the unsupported ones are replaced
+// by functions that stop the run with an Error when they are called.
+func Bind(fn interface{}) func(*Context) interface{} {
+	if f, ok := fn.(func(*Context) interface{}); ok {
+		return f
+	}
+
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if err := Bindable(t); err != nil {
+		panic("gisp: " + err.Error())
+	}
+
+	// the number of the leading context parameters
+	skip := 0
+	if t.NumIn() > 0 && (t.In(0) == typeContext || t.In(0) == typeGoCtx) {
+		skip = 1
+	}
+
+	return func(ctx *Context) interface{} {
+		in := make([]reflect.Value, 0, t.NumIn())
+
+		if skip == 1 {
+			if t.In(0) == typeContext {
+				in = append(in, reflect.ValueOf(ctx))
+			} else {
+				goCtx, ok := ctx.ENV.(context.Context)
+				if !ok {
+					goCtx = context.Background()
+				}
+				in = append(in, reflect.ValueOf(&goCtx).Elem())
+			}
+		}
+
+		n := t.NumIn() - skip
+		if t.IsVariadic() {
+			n--
+		}
+
+		for i := 0; i < n; i++ {
+			in = append(in, bindArg(ctx, i+1, ctx.Arg(i+1), t.In(i+skip)))
+		}
+
+		if t.IsVariadic() {
+			elem := t.In(t.NumIn() - 1).Elem()
+			for i := n + 1; i < ctx.Len(); i++ {
+				in = append(in, bindArg(ctx, i, ctx.Arg(i), elem))
+			}
+		}
+
+		out := v.Call(in)
+
+		if len(out) > 0 && t.Out(len(out)-1) == typeError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				if e, ok := err.(Error); ok {
+					panic(e)
+				}
+				ctx.Error(err.Error())
+			}
+			out = out[:len(out)-1]
+		}
+
+		if len(out) == 0 {
+			return nil
+		}
+		return ToNumber(out[0].Interface())
+	}
+}
+
+// Bindable checks if the func type can be wrapped by Bind, the error tells the reason
+func Bindable(t reflect.Type) error {
+	if t.Kind() != reflect.Func {
+		return fmt.Errorf("Bind expects a function, got %s", t)
+	}
+
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != typeError:
+		return fmt.Errorf("Bind doesn't support the results of %s", t)
+	}
+	return nil
+}
+
+func bindArg(ctx *Context, index int, val interface{}, t reflect.Type) reflect.Value {
+	v, err := convertValue(val, t)
+	if err != nil {
+		ctx.Error(fmt.Sprintf("argument %d: %s", index, err))
+	}
+	return v
+}
+
+// convertValue converts the script value to the Go type
+func convertValue(val interface{}, t reflect.Type) (reflect.Value, error) {
+	if val == nil {
+		return reflect.Zero(t), nil
+	}
+
+	v := reflect.ValueOf(val)
+	if v.Type().AssignableTo(t) {
+		out := reflect.New(t).Elem()
+		out.Set(v)
+		return out, nil
+	}
+
+	fail := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot use %T as %s", val, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(val)
+		if !ok {
+			return fail()
+		}
+		return reflect.ValueOf(f).Convert(t), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		r, ok := toRat(val)
+		if !ok {
+			return fail()
+		}
+		if !r.IsInt() {
+			return reflect.Value{}, fmt.Errorf("%v is not an integer", val)
+		}
+
+		out := reflect.New(t).Elem()
+		i := r.Num()
+		if t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr {
+			if i.Sign() < 0 || !i.IsUint64() || out.OverflowUint(i.Uint64()) {
+				return reflect.Value{}, fmt.Errorf("%v overflows %s", val, t)
+			}
+			out.SetUint(i.Uint64())
+		} else {
+			if !i.IsInt64() || out.OverflowInt(i.Int64()) {
+				return reflect.Value{}, fmt.Errorf("%v overflows %s", val, t)
+			}
+			out.SetInt(i.Int64())
+		}
+		return out, nil
+
+	case reflect.Slice:
+		arr, ok := val.([]interface{})
+		if !ok {
+			return fail()
+		}
+		out := reflect.MakeSlice(t, len(arr), len(arr))
+		for i, item := range arr {
+			el, err := convertValue(item, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(el)
+		}
+		return out, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return fail()
+		}
+
+		var keys []string
+		get := func(string) interface{} { return nil }
+		switch dict := val.(type) {
+		case map[string]interface{}:
+			for k := range dict {
+				keys = append(keys, k)
+			}
+			get = func(k string) interface{} { return dict[k] }
+		case *Dict:
+			keys = dict.Keys()
+			get = func(k string) interface{} { v, _ := dict.Get(k); return v }
+		default:
+			return fail()
+		}
+
+		out := reflect.MakeMapWithSize(t, len(keys))
+		for _, k := range keys {
+			el, err := convertValue(get(k), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), el)
+		}
+		return out, nil
+
+	case reflect.String, reflect.Bool:
+		if v.Kind() == t.Kind() {
+			return v.Convert(t), nil
+		}
+	}
+
+	if t == typeDict {
+		if m, ok := val.(map[string]interface{}); ok {
+			return reflect.ValueOf(DictFromMap(m)), nil
+		}
+	}
+
+	return fail()
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	switch n := val.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case Decimal:
+		return n.Float64(), true
+	}
+	return 0, false
+}
+
+func toRat(val interface{}) (*big.Rat, bool) {
+	switch n := val.(type) {
+	case float64:
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(n), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case Decimal:
+		return n.Rat(), true
+	}
+	return nil, false
+}
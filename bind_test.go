@@ -0,0 +1,126 @@
+package gisp_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+)
+
+// runBind runs the code, returns the panic as the error
+func runBind(code string, box gisp.Box, env interface{}) (out interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(gisp.Error)
+		}
+	}()
+
+	return gisp.RunJSON(code, &gisp.Context{
+		Sandbox:     gisp.New(box),
+		ENV:         env,
+		IsLiftPanic: true,
+	})
+}
+
+func TestBind(t *testing.T) {
+	div := func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	}
+
+	out, err := runBind(`["div", 1, 4]`, gisp.Box{"div": div}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.25, out)
+
+	_, err = runBind(`["div", 1, 0]`, gisp.Box{"div": div}, nil)
+	assert.EqualError(t, err, "division by zero")
+	assert.Equal(t, []interface{}{"div", 0}, err.(gisp.Error).Stack)
+}
+
+func TestBindContext(t *testing.T) {
+	type key struct{}
+	has := func(ctx context.Context, id string) bool {
+		return ctx.Value(key{}) == id
+	}
+
+	env := context.WithValue(context.Background(), key{}, "a")
+	out, _ := runBind(`["has", "a"]`, gisp.Box{"has": has}, env)
+	assert.Equal(t, true, out)
+
+	out, _ = runBind(`["has", "a"]`, gisp.Box{"has": has}, nil)
+	assert.Equal(t, false, out)
+
+	// the old style that returns a concrete type
+	add := func(ctx *gisp.Context) float64 {
+		return ctx.ArgNum(1) + ctx.ArgNum(2)
+	}
+	out, _ = runBind(`["+", 1, 2]`, gisp.Box{"+": add}, nil)
+	assert.Equal(t, float64(3), out)
+}
+
+func TestBindVariadic(t *testing.T) {
+	join := func(sep string, list ...string) string {
+		return strings.Join(list, sep)
+	}
+	out, _ := runBind(`["join", "-", "a", "b", "c"]`, gisp.Box{"join": join}, nil)
+	assert.Equal(t, "a-b-c", out)
+
+	out, _ = runBind(`["join", "-"]`, gisp.Box{"join": join}, nil)
+	assert.Equal(t, "", out)
+}
+
+func TestBindConvert(t *testing.T) {
+	sum := func(list []int, weights map[string]uint8) int {
+		n := 0
+		for _, v := range list {
+			n += v
+		}
+		for _, w := range weights {
+			n += int(w)
+		}
+		return n
+	}
+	box := gisp.Box{
+		"sum": sum,
+		"$":   func(ctx *gisp.Context) interface{} { return ctx.AST.([]interface{})[1] },
+	}
+
+	out, err := runBind(`["sum", ["$", [1, 2]], {"a": 3}]`, box, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), out)
+
+	out, err = runBind(`["sum"]`, box, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), out)
+
+	_, err = runBind(`["sum", ["$", [1.5]]]`, box, nil)
+	assert.EqualError(t, err, "argument 1: 1.5 is not an integer")
+
+	_, err = runBind(`["sum", null, {"a": 300}]`, box, nil)
+	assert.EqualError(t, err, "argument 2: 300 overflows uint8")
+
+	_, err = runBind(`["sum", "a"]`, box, nil)
+	assert.EqualError(t, err, "argument 1: cannot use string as []int")
+}
+
+func TestBindInvalid(t *testing.T) {
+	assert.Panics(t, func() { gisp.Bind(1) })
+	assert.Panics(t, func() { gisp.Bind(func() (int, int) { return 0, 0 }) })
+
+	noop := func() {}
+	out, _ := runBind(`["noop"]`, gisp.Box{"noop": noop}, nil)
+	assert.Nil(t, out)
+
+	pair := func() (int, int) { return 0, 0 }
+	sandbox := gisp.New(gisp.Box{"pair": pair})
+	val, _ := sandbox.Get("pair")
+	assert.IsType(t, (func(*gisp.Context) interface{})(nil), val)
+
+	_, err := runBind(`["pair"]`, gisp.Box{"pair": pair}, nil)
+	assert.EqualError(t, err, `function "pair" can't be called: Bind doesn't support the results of func() (int, int)`)
+}
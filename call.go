@@ -0,0 +1,80 @@
+package gisp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Call calls the fn with the evaluated args, they won't be evaluated again.
+// The fn can be a sandbox function such as a closure created by lib.Fn,
+// a Go func which will be wrapped by Bind, or the name of a value in the sandbox of the ctx.
+// The args are converted the same way as the values put into the Sandbox.
+// The special forms such as lib.Switch and lib.For read their arguments as code, they
+// can't be called with values.
+// Like Run, it panics with Error when something goes wrong.
+func Call(ctx *Context, fn interface{}, args ...interface{}) interface{} {
+	if ctx == nil {
+		ctx = &Context{Sandbox: New(Box{})}
+	}
+
+	head := fn
+	if name, ok := fn.(string); ok {
+		val, has := ctx.Sandbox.Get(name)
+		if !has {
+			msg, _ := json.Marshal(name)
+			ctx.Error("function " + string(msg) + " is undefined")
+		}
+		fn = val
+	}
+
+	fn, _ = hostValue(fn)
+	f, ok := fn.(func(*Context) interface{})
+	if !ok {
+		ctx.Error(fmt.Sprintf("%T is not callable", fn))
+	}
+
+	list := make([]interface{}, len(args))
+	ast := make([]interface{}, len(args)+1)
+	ast[0] = head
+	for i, arg := range args {
+		list[i], _ = hostValue(arg)
+		// the values in the AST are quoted, so they won't run as code if the fn reads the AST
+		ast[i+1] = []interface{}{quote, list[i]}
+	}
+
+	return f(&Context{
+		AST:         ast,
+		Args:        list,
+		Sandbox:     ctx.Sandbox,
+		ENV:         ctx.ENV,
+		Index:       ctx.Index,
+		Parent:      ctx,
+		IsLiftPanic: ctx.IsLiftPanic,
+		PreRun:      ctx.PreRun,
+		PostRun:     ctx.PostRun,
+	})
+}
+
+// quote returns its argument without evaluating it
+func quote(ctx *Context) interface{} {
+	return ctx.AST.([]interface{})[1]
+}
+
+// Invoke calls the fn from Go with the args, see Call.
+// The panic is returned as the error.
+func Invoke(fn interface{}, args ...interface{}) (ret interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch v := r.(type) {
+			case Error:
+				err = v
+			case error:
+				err = v
+			default:
+				err = fmt.Errorf("%v", v)
+			}
+		}
+	}()
+
+	return Call(nil, fn, args...), nil
+}
@@ -0,0 +1,86 @@
+package gisp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func TestCall(t *testing.T) {
+	sandbox := gisp.New(lib.Std())
+
+	fn, _ := gisp.RunJSON(`["fn", ["a", "b"], ["+", ["get", ["a"], "0"], ["b"]]]`, &gisp.Context{Sandbox: sandbox})
+
+	// the array won't be evaluated as a call
+	out, err := gisp.Invoke(fn, []interface{}{"x"}, "y")
+	assert.Nil(t, err)
+	assert.Equal(t, "xy", out)
+
+	out, err = gisp.Invoke(fn, []interface{}{1}, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), out)
+
+	out, err = gisp.Invoke(func(a, b int) int { return a * b }, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), out)
+
+	_, err = gisp.Invoke(1)
+	assert.EqualError(t, err, "int64 is not callable")
+
+	_, err = gisp.Invoke(lib.Throw, "err")
+	assert.EqualError(t, err, "err")
+}
+
+func TestCallFromLib(t *testing.T) {
+	sandbox := gisp.New(lib.Std())
+	sandbox.Set("twice", func(ctx *gisp.Context) interface{} {
+		f := ctx.Arg(1)
+		return gisp.Call(ctx, f, gisp.Call(ctx, f, ctx.Arg(2)))
+	})
+
+	out, _ := gisp.RunJSON(`["twice", ["fn", ["a"], ["concat", ["a"], ["a"]]], ["|", 1]]`, &gisp.Context{Sandbox: sandbox})
+	assert.Equal(t, []interface{}{float64(1), float64(1), float64(1), float64(1)}, out)
+
+	ctx := &gisp.Context{Sandbox: sandbox}
+	assert.Equal(t, int64(3), gisp.Call(ctx, "+", 1, 2))
+	assert.Panics(t, func() { gisp.Call(ctx, "nope") })
+}
+
+func TestCallSpecialForm(t *testing.T) {
+	sandbox := gisp.New(lib.Std())
+	sandbox.Set("secret", "s3cr3t")
+	sandbox.Set("env", func(ctx *gisp.Context) interface{} { return ctx.ENV })
+
+	run := func(code string, env interface{}) (interface{}, error) {
+		return gisp.Invoke(func() interface{} {
+			out, _ := gisp.RunJSON(code, &gisp.Context{Sandbox: sandbox, ENV: env})
+			return out
+		})
+	}
+
+	// the values from the request data won't run as code
+	env := map[string]interface{}{"rows": []interface{}{
+		[]interface{}{"case", true, []interface{}{"secret"}},
+	}}
+	_, err := run(`["map", ["get", ["env"], "rows"], "switch"]`, env)
+	assert.EqualError(t, err, "switch can't be called with values")
+
+	_, err = run(`["map", ["$", [1]], "for"]`, nil)
+	assert.EqualError(t, err, "for can't be called with values")
+
+	_, err = run(`["map", ["$", [["a"]]], "fn"]`, nil)
+	assert.EqualError(t, err, "fn can't be called with values")
+
+	out, err := run(`["map", ["$", [["secret"]]], "$"]`, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{"secret"}}, out)
+
+	// a function that reads the AST gets the quoted values
+	out, err = gisp.Invoke(func(ctx *gisp.Context) interface{} {
+		return gisp.Run(&gisp.Context{AST: ctx.AST.([]interface{})[1], Sandbox: sandbox})
+	}, []interface{}{"secret"})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"secret"}, out)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ysmood/gisp/format"
+)
+
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write result to the source file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		out, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintln(stderr, "<stdin>:", err)
+			return 1
+		}
+		_, _ = stdout.Write(out)
+		return 0
+	}
+
+	code := 0
+	for _, path := range flags.Args() {
+		if err := fmtFile(path, *write); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+func fmtFile(path string, write bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	out, err := format.Source(src)
+	if err != nil {
+		return err
+	}
+
+	if !write {
+		_, err = stdout.Write(out)
+		return err
+	}
+
+	if bytes.Equal(src, out) {
+		return nil
+	}
+	return os.WriteFile(path, out, 0644)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var errInterrupt = errors.New("interrupt")
+
+// lineReader reads lines from the input, when the input is a terminal
+// it supports editing, history and tab completion
+type lineReader struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       uintptr
+	terminal bool
+	history  []string
+
+	// complete returns the candidates for the text before the cursor,
+	// and the rune index where the replaced token starts
+	complete func(text string) ([]string, int)
+}
+
+func newLineReader(in io.Reader, out io.Writer, complete func(string) ([]string, int)) *lineReader {
+	r := &lineReader{
+		in:       bufio.NewReader(in),
+		out:      out,
+		complete: complete,
+	}
+
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		r.fd = f.Fd()
+		r.terminal = true
+	}
+	return r
+}
+
+func (r *lineReader) readLine(prompt string) (string, error) {
+	if !r.terminal {
+		line, err := r.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	e := &editor{r: r, prompt: prompt, histIndex: len(r.history)}
+	line, err := e.run()
+	if err == nil && strings.TrimSpace(line) != "" {
+		r.history = append(r.history, line)
+	}
+	return line, err
+}
+
+type editor struct {
+	r         *lineReader
+	prompt    string
+	buf       []rune
+	pos       int
+	histIndex int
+}
+
+func (e *editor) run() (string, error) {
+	e.redraw()
+
+	for {
+		c, _, err := e.r.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch c {
+		case '\r', '\n':
+			fmt.Fprint(e.r.out, "\r\n")
+			return string(e.buf), nil
+		case 3: // ctrl-c
+			fmt.Fprint(e.r.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // ctrl-d
+			if len(e.buf) == 0 {
+				fmt.Fprint(e.r.out, "\r\n")
+				return "", io.EOF
+			}
+			e.delete(e.pos)
+		case 127, 8: // backspace
+			if e.pos > 0 {
+				e.pos--
+				e.delete(e.pos)
+			}
+		case 1: // ctrl-a
+			e.pos = 0
+		case 5: // ctrl-e
+			e.pos = len(e.buf)
+		case 21: // ctrl-u
+			e.buf = e.buf[e.pos:]
+			e.pos = 0
+		case '\t':
+			e.tab()
+		case 27:
+			e.escape()
+		default:
+			if c >= 32 {
+				e.buf = append(e.buf[:e.pos], append([]rune{c}, e.buf[e.pos:]...)...)
+				e.pos++
+			}
+		}
+
+		e.redraw()
+	}
+}
+
+func (e *editor) delete(i int) {
+	if i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+func (e *editor) redraw() {
+	out := "\r\033[K" + e.prompt + string(e.buf)
+	if n := len(e.buf) - e.pos; n > 0 {
+		out += fmt.Sprintf("\033[%dD", n)
+	}
+	fmt.Fprint(e.r.out, out)
+}
+
+// escape handles the arrow keys
+func (e *editor) escape() {
+	c, _, err := e.r.in.ReadRune()
+	if err != nil || c != '[' {
+		return
+	}
+
+	c, _, err = e.r.in.ReadRune()
+	if err != nil {
+		return
+	}
+
+	switch c {
+	case 'A':
+		e.recall(-1)
+	case 'B':
+		e.recall(1)
+	case 'C':
+		if e.pos < len(e.buf) {
+			e.pos++
+		}
+	case 'D':
+		if e.pos > 0 {
+			e.pos--
+		}
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.buf)
+	default:
+		// skip the rest of sequences like "3~"
+		for c >= '0' && c <= '9' || c == ';' {
+			if c, _, err = e.r.in.ReadRune(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (e *editor) recall(step int) {
+	i := e.histIndex + step
+	if i < 0 || i > len(e.r.history) {
+		return
+	}
+	e.histIndex = i
+
+	if i == len(e.r.history) {
+		e.buf = nil
+	} else {
+		e.buf = []rune(e.r.history[i])
+	}
+	e.pos = len(e.buf)
+}
+
+func (e *editor) tab() {
+	if e.r.complete == nil {
+		return
+	}
+
+	candidates, start := e.r.complete(string(e.buf[:e.pos]))
+	if len(candidates) == 0 {
+		fmt.Fprint(e.r.out, "\a")
+		return
+	}
+
+	prefix := commonPrefix(candidates)
+	if len([]rune(prefix)) > e.pos-start {
+		rest := append([]rune(prefix), e.buf[e.pos:]...)
+		e.buf = append(e.buf[:start:start], rest...)
+		e.pos = start + len([]rune(prefix))
+		return
+	}
+
+	if len(candidates) > 1 {
+		fmt.Fprint(e.r.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
+
+func commonPrefix(list []string) string {
+	prefix := []rune(list[0])
+	for _, s := range list[1:] {
+		i := 0
+		for _, r := range s {
+			if i >= len(prefix) || prefix[i] != r {
+				break
+			}
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return string(prefix)
+}
+
+// completeNames completes the token before the cursor with the names
+func completeNames(names []string, text string) ([]string, int) {
+	runes := []rune(text)
+	start := len(runes)
+	for start > 0 && !strings.ContainsRune(" \t()[]{}\",", runes[start-1]) {
+		start--
+	}
+	prefix := string(runes[start:])
+
+	seen := map[string]bool{}
+	candidates := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, start
+}
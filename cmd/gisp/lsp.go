@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lsp"
+)
+
+func runLsp(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	namesPath := flags.String("names", "", "the json file of the extra names provided by the host, such as {\"user\": \"the current user\"}")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		return 2
+	}
+
+	server := lsp.New(newSandbox())
+
+	if *namesPath != "" {
+		data, err := os.ReadFile(*namesPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if err := json.Unmarshal(data, &server.Docs); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", *namesPath, err)
+			return 1
+		}
+
+		// the host names only need to exist for the checks
+		server.Sandbox = server.Sandbox.Create()
+		for name := range server.Docs {
+			server.Sandbox.Set(name, func(*gisp.Context) interface{} { return nil })
+		}
+	}
+
+	if err := server.Serve(stdin, stdout); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
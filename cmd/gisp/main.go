@@ -0,0 +1,69 @@
+// Command gisp is the toolbox for gisp scripts.
+//
+// Usage:
+//
+//	gisp run [--env env.json] [--exact] script.json
+//	gisp fmt [-w] [files...]
+//	gisp repl [--exact]
+//	gisp lsp [--names names.json]
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) int
+}
+
+var commands = map[string]command{
+	"run":  {"run a script and print the result as json", runRun},
+	"fmt":  {"format gisp json files", runFmt},
+	"repl": {"start an interactive session", runRepl},
+	"lsp":  {"start the language server on stdio", runLsp},
+}
+
+// the standard io, they are replaced in tests
+var (
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) < 1 {
+		usage()
+		return 2
+	}
+
+	cmd, has := commands[args[0]]
+	if !has {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage()
+		return 2
+	}
+
+	return cmd.run(args[1:])
+}
+
+func usage() {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(stderr, "usage: gisp <command> [arguments]")
+	fmt.Fprintln(stderr)
+	for _, name := range names {
+		fmt.Fprintf(stderr, "  %-8s %s\n", name, commands[name].usage)
+	}
+}
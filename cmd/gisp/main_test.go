@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exec runs the command with the input, returns the exit code, stdout and stderr
+func exec(input string, args ...string) (int, string, string) {
+	var out, errOut bytes.Buffer
+	stdin, stdout, stderr = strings.NewReader(input), &out, &errOut
+	code := run(args)
+	return code, out.String(), errOut.String()
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	script := writeFile(t, "s.json", `[":", "b", ["+", ["get", ["env"], "n"], 1], "a", "<x>"]`)
+	env := writeFile(t, "env.json", `{"n": 9007199254740993}`)
+
+	code, out, _ := exec("", "run", script, "--env", env, "--exact")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "{\n  \"b\": 9007199254740994,\n  \"a\": \"<x>\"\n}\n", out)
+}
+
+func TestRunStdin(t *testing.T) {
+	code, out, _ := exec(`["+", 1, 2]`, "run", "-")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "3\n", out)
+}
+
+func TestRunSexp(t *testing.T) {
+	script := writeFile(t, "s.sexp", `(do (def f (fn (a) (* (a) 2))) (f 3))`)
+
+	code, out, _ := exec("", "run", script)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "6\n", out)
+
+	code, _, errOut := exec("", "run", script, "--exact")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, script+": --exact is not supported for .sexp files\n", errOut)
+}
+
+func TestRunError(t *testing.T) {
+	code, _, errOut := exec(`["+", 1, ["foo"]]`, "run", "-")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "error: function \"foo\" is undefined\nfoo:2\n+:0\n", errOut)
+
+	code, _, errOut = exec(`[`, "run", "-")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "<stdin>: unexpected end of JSON input\n", errOut)
+
+	code, _, _ = exec("", "run")
+	assert.Equal(t, 2, code)
+}
+
+func TestFmt(t *testing.T) {
+	code, out, _ := exec(`[ "+",1 ]`, "fmt")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "[\"+\", 1]\n", out)
+
+	path := writeFile(t, "s.json", `[ "+",1 ]`)
+	code, _, _ = exec("", "fmt", "-w", path)
+	assert.Equal(t, 0, code)
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "[\"+\", 1]\n", string(data))
+}
+
+func TestUnknownCommand(t *testing.T) {
+	code, _, errOut := exec("", "foo")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, `unknown command "foo"`)
+}
+
+func TestLsp(t *testing.T) {
+	names := writeFile(t, "names.json", `{"user": "the current user"}`)
+	body := `{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"a","text":"[\"get\", [\"user\"], \"id\"]"}}}`
+
+	code, out, _ := exec(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body), "lsp", "--names", names)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"diagnostics":[]`)
+}
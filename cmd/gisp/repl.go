@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+const replHelp = `Enter a JSON or S-expression, such as ["+", 1, 2] or (+ 1 2).
+Commands:
+  :load file   run a script file in the session
+  :env file    set the ENV from a json file
+  :doc name    show the documentation of a function
+  :names       list the defined names
+  :help        show this help
+  :quit        exit the repl
+`
+
+type session struct {
+	sandbox *gisp.Sandbox
+	env     interface{}
+	exact   bool
+}
+
+func runRepl(args []string) int {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	exact := flags.Bool("exact", false, "decode json numbers as int64 and decimal")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		return 2
+	}
+
+	s := &session{sandbox: newSandbox(), exact: *exact}
+	reader := newLineReader(stdin, stdout, func(text string) ([]string, int) {
+		return completeNames(s.sandbox.Names(), text)
+	})
+
+	if reader.terminal {
+		fmt.Fprintln(stdout, "gisp repl, type :help for help")
+	}
+
+	for {
+		src, err := readInput(reader)
+		if err == errInterrupt {
+			continue
+		}
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return 1
+		}
+
+		if s.exec(src) {
+			return 0
+		}
+	}
+}
+
+// readInput reads lines until the brackets are balanced
+func readInput(reader *lineReader) (string, error) {
+	src := ""
+	prompt := "gisp> "
+	for {
+		line, err := reader.readLine(prompt)
+		if err != nil {
+			if err == io.EOF && src != "" {
+				return src, nil
+			}
+			return "", err
+		}
+
+		src += line + "\n"
+		if depth(src) <= 0 {
+			return src, nil
+		}
+		prompt = "...   "
+	}
+}
+
+// depth returns the number of the unclosed brackets, strings and comments are skipped
+func depth(src string) int {
+	d := 0
+	var quote rune
+	escaped := false
+	comment := false
+
+	for _, c := range src {
+		switch {
+		case comment:
+			comment = c != '\n'
+		case quote != 0:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"':
+			quote = c
+		case c == ';':
+			comment = true
+		case c == '(' || c == '[' || c == '{':
+			d++
+		case c == ')' || c == ']' || c == '}':
+			d--
+		}
+	}
+	return d
+}
+
+// exec runs the input, returns true if the session should end
+func (s *session) exec(src string) bool {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return false
+	}
+
+	if strings.HasPrefix(src, ":") {
+		return s.command(src)
+	}
+
+	ast, err := s.parse(src)
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return false
+	}
+	s.run(ast)
+	return false
+}
+
+// parse decodes the input as json if it looks like json, or as S-expression
+func (s *session) parse(src string) (interface{}, error) {
+	if strings.HasPrefix(src, "[") || strings.HasPrefix(src, "{") {
+		return decode(".json", []byte(src), s.exact)
+	}
+	return decode(".sexp", []byte(src), s.exact)
+}
+
+func (s *session) run(ast interface{}) {
+	out, err := eval(&gisp.Context{
+		AST:         ast,
+		Sandbox:     s.sandbox,
+		ENV:         s.env,
+		IsLiftPanic: true,
+	})
+	if err != nil {
+		if e, ok := err.(gisp.Error); ok {
+			fmt.Fprint(stderr, "error: ", e.String())
+		} else {
+			fmt.Fprintln(stderr, "error:", err)
+		}
+		return
+	}
+
+	printValue(out)
+}
+
+func (s *session) command(src string) bool {
+	fields := strings.Fields(src)
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case ":quit", ":q":
+		return true
+	case ":help", ":h":
+		fmt.Fprint(stdout, replHelp)
+	case ":names":
+		names := s.sandbox.Names()
+		sort.Strings(names)
+		fmt.Fprintln(stdout, strings.Join(names, " "))
+	case ":load", ":env", ":doc":
+		if len(args) != 1 {
+			fmt.Fprintf(stderr, "usage: %s <%s>\n", name, map[string]string{
+				":load": "file", ":env": "file", ":doc": "name",
+			}[name])
+			return false
+		}
+		switch name {
+		case ":load":
+			ast, err := loadScript(args[0], s.exact)
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", args[0], err)
+				return false
+			}
+			s.run(ast)
+		case ":env":
+			env, err := loadScript(args[0], s.exact)
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", args[0], err)
+				return false
+			}
+			s.env = env
+		case ":doc":
+			s.doc(args[0])
+		}
+	default:
+		fmt.Fprintf(stderr, "unknown command %q, type :help for help\n", name)
+	}
+	return false
+}
+
+func (s *session) doc(name string) {
+	val, has := s.sandbox.Get(name)
+	if !has {
+		fmt.Fprintf(stderr, "%q is undefined\n", name)
+		return
+	}
+
+	if doc, ok := lib.Doc(val); ok {
+		fmt.Fprintln(stdout, doc)
+		return
+	}
+
+	if _, ok := val.(func(*gisp.Context) interface{}); ok {
+		fmt.Fprintf(stdout, "%s is a function without documentation\n", name)
+		return
+	}
+
+	printValue(val)
+}
+
+func printValue(val interface{}) {
+	if _, ok := val.(func(*gisp.Context) interface{}); ok {
+		fmt.Fprintln(stdout, "<function>")
+		return
+	}
+
+	if err := printJSON(val); err != nil {
+		fmt.Fprintln(stdout, fmt.Sprint(val))
+	}
+}
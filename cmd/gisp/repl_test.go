@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepl(t *testing.T) {
+	env := writeFile(t, "env.json", `{"n": 2}`)
+	script := writeFile(t, "s.sexp", `(def double (fn (a) (* (a) 2)))`)
+
+	input := `(def x 1)
+["+", ["x"],
+  1]
+:env ` + env + `
+:load ` + script + `
+(double (get (env) n))
+(foo)
+:doc get
+(fn)
+:quit
+(x)
+`
+	code, out, errOut := exec(input, "repl")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "1\n2\n<function>\n4\n"+
+		"(get obj path default) gets the value of the path, such as \"a.b.0\", returns the default if not found\n"+
+		"<function>\n", out)
+	assert.Equal(t, "error: function \"foo\" is undefined\nfoo:0\n", errOut)
+}
+
+func TestReplBadInput(t *testing.T) {
+	code, _, errOut := exec("(+ 1\n:foo\n:doc\n", "repl")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, errOut, "error: ")
+}
+
+func TestDepth(t *testing.T) {
+	assert.Equal(t, 1, depth(`["a", "]", `))
+	assert.Equal(t, 0, depth("(a ; )\n)"))
+	assert.Equal(t, 0, depth(`"\")"`))
+}
+
+func TestCompleteNames(t *testing.T) {
+	names := []string{"get", "if", "indexOf", "includes", "if"}
+
+	list, start := completeNames(names, `["i`)
+	assert.Equal(t, []string{"if", "includes", "indexOf"}, list)
+	assert.Equal(t, 2, start)
+
+	list, start = completeNames(names, `(do (ge`)
+	assert.Equal(t, []string{"get"}, list)
+	assert.Equal(t, 5, start)
+
+	assert.Equal(t, "in", commonPrefix([]string{"includes", "indexOf"}))
+	assert.Equal(t, "a", commonPrefix([]string{"aé", "aè"}))
+	assert.Equal(t, "", commonPrefix([]string{"é", "b"}))
+}
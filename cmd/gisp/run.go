@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	envPath := flags.String("env", "", "the json file used as the ENV of the script, it's readable via [\"env\"]")
+	exact := flags.Bool("exact", false, "decode json numbers as int64 and decimal")
+
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 1 {
+		fmt.Fprintln(stderr, "usage: gisp run [--env env.json] [--exact] script.json")
+		return 2
+	}
+
+	ast, err := loadScript(files[0], *exact)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", displayName(files[0]), err)
+		return 1
+	}
+
+	var env interface{}
+	if *envPath != "" {
+		env, err = loadScript(*envPath, *exact)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", displayName(*envPath), err)
+			return 1
+		}
+	}
+
+	out, err := eval(&gisp.Context{
+		AST:         ast,
+		Sandbox:     newSandbox(),
+		ENV:         env,
+		IsLiftPanic: true,
+	})
+	if err != nil {
+		if e, ok := err.(gisp.Error); ok {
+			fmt.Fprint(stderr, "error: ", e.String())
+		} else {
+			fmt.Fprintln(stderr, "error:", err)
+		}
+		return 1
+	}
+
+	if err := printJSON(out); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// parseFlags allows flags to appear after the positional arguments
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	rest := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			return rest, nil
+		}
+		rest = append(rest, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// loadScript decodes the file by its extension, ".sexp" and ".lisp" for S-expression,
+// ".json5" for json5, others for json. "-" reads from stdin.
+func loadScript(path string, exact bool) (interface{}, error) {
+	var src []byte
+	var err error
+	if path == "-" {
+		src, err = io.ReadAll(stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(filepath.Ext(path), src, exact)
+}
+
+func displayName(path string) string {
+	if path == "-" {
+		return "<stdin>"
+	}
+	return path
+}
+
+func decode(ext string, src []byte, exact bool) (interface{}, error) {
+	switch ext = strings.ToLower(ext); ext {
+	case ".sexp", ".lisp", ".json5":
+		if exact {
+			return nil, fmt.Errorf("--exact is not supported for %s files", ext)
+		}
+		if ext == ".json5" {
+			return gisp.Relaxed{Ordered: true}.Decode(src)
+		}
+		return gisp.SExp{Ordered: true}.Decode(src)
+	}
+
+	decoder := gisp.JSON{Ordered: true}
+	if exact {
+		decoder.UseNumber = true
+		decoder.Number = gisp.ExactNumber
+	}
+	return decoder.Decode(src)
+}
+
+func newSandbox() *gisp.Sandbox {
+	box := lib.Std()
+	box["env"] = func(ctx *gisp.Context) interface{} {
+		return ctx.ENV
+	}
+	return gisp.New(box)
+}
+
+// eval runs the context and turns the panic into error
+func eval(ctx *gisp.Context) (ret interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch v := r.(type) {
+			case gisp.Error:
+				err = v
+			case error:
+				err = v
+			default:
+				err = errors.New(fmt.Sprint(v))
+			}
+		}
+	}()
+
+	return gisp.Run(ctx), nil
+}
+
+func printJSON(val interface{}) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(val)
+}
@@ -0,0 +1,274 @@
+package gisp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/a8m/djson"
+	"github.com/ysmood/gisp/json5"
+	"github.com/ysmood/gisp/msgpack"
+	"github.com/ysmood/gisp/sexp"
+)
+
+// Decoder decodes the source code into AST.
+//
+// The AST is a tree that only contains these types:
+//
+//	null   -> nil
+//	bool   -> bool
+//	number -> float64, int64 or Decimal
+//	string -> string
+//	array  -> []interface{}
+//	object -> map[string]interface{} or *Dict
+//
+// Use Validate to check if an AST follows the contract.
+type Decoder interface {
+	Decode(code []byte) (interface{}, error)
+}
+
+// DecoderFunc adapts a function to Decoder
+type DecoderFunc func(code []byte) (interface{}, error)
+
+// Decode ...
+func (fn DecoderFunc) Decode(code []byte) (interface{}, error) {
+	return fn(code)
+}
+
+// DJSON the default decoder, it's based on github.com/a8m/djson
+var DJSON Decoder = DecoderFunc(djson.Decode)
+
+// JSON5 the decoder for json that has comments, trailing commas,
+// single-quoted strings or unquoted keys
+var JSON5 Decoder = DecoderFunc(json5.Decode)
+
+// MsgPack the decoder for the binary AST encoded by msgpack.Encode
+var MsgPack Decoder = DecoderFunc(msgpack.Decode)
+
+// Binary the decoder for the binary AST encoded by msgpack.Encode with options
+type Binary struct {
+	// Exact decodes the integers as int64 and the decimals as Decimal, see ExactNumber
+	Exact bool
+
+	// Ordered decodes dicts as *Dict to keep the key order of the source
+	Ordered bool
+}
+
+// Decode ...
+func (d Binary) Decode(code []byte) (interface{}, error) {
+	opts := msgpack.Decoder{}
+	if d.Exact {
+		opts.Number = func(s string) (interface{}, error) {
+			return ExactNumber(json.Number(s))
+		}
+	}
+	if d.Ordered {
+		opts.Dict = orderedDict
+	}
+	return opts.Decode(code)
+}
+
+// Relaxed the decoder for json5 with options, see JSON5
+type Relaxed struct {
+	// Ordered decodes objects as *Dict to keep the key order of the source
+	Ordered bool
+}
+
+// Decode ...
+func (d Relaxed) Decode(code []byte) (interface{}, error) {
+	opts := json5.Decoder{}
+	if d.Ordered {
+		opts.Dict = orderedDict
+	}
+	return opts.Decode(code)
+}
+
+// SExp the decoder for the S-expression, see sexp.Parse
+type SExp struct {
+	// Ordered decodes dicts as *Dict to keep the key order of the source
+	Ordered bool
+}
+
+// Decode ...
+func (d SExp) Decode(code []byte) (interface{}, error) {
+	opts := sexp.Parser{}
+	if d.Ordered {
+		opts.Dict = orderedDict
+	}
+	return opts.Parse(code)
+}
+
+// orderedDict creates the *Dict in the order of the keys, the later duplicated key wins
+func orderedDict(keys []string, vals []interface{}) interface{} {
+	dict := NewDict()
+	for i, k := range keys {
+		dict.Set(k, vals[i])
+	}
+	return dict
+}
+
+// JSON the decoder based on encoding/json
+type JSON struct {
+	// UseNumber decodes numbers as json.Number, then converts them via the Number
+	UseNumber bool
+
+	// Number converts the json.Number to the AST number,
+	// if it's nil the number will be converted to float64
+	Number func(json.Number) (interface{}, error)
+
+	// Ordered decodes objects as *Dict to keep the key order of the source
+	Ordered bool
+}
+
+// Decode ...
+func (d JSON) Decode(code []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(code))
+	if d.UseNumber {
+		dec.UseNumber()
+	}
+
+	var ast interface{}
+	var err error
+	if d.Ordered {
+		ast, err = decodeOrdered(dec)
+	} else {
+		err = dec.Decode(&ast)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid data after the top-level value")
+	}
+
+	if !d.UseNumber {
+		return ast, nil
+	}
+	return convertNumber(ast, d.Number)
+}
+
+func convertNumber(ast interface{}, conv func(json.Number) (interface{}, error)) (interface{}, error) {
+	switch v := ast.(type) {
+	case json.Number:
+		if conv == nil {
+			return v.Float64()
+		}
+		return conv(v)
+	case []interface{}:
+		for i, el := range v {
+			val, err := convertNumber(el, conv)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = val
+		}
+	case map[string]interface{}:
+		for k, el := range v {
+			val, err := convertNumber(el, conv)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = val
+		}
+	case *Dict:
+		for k, el := range v.vals {
+			val, err := convertNumber(el, conv)
+			if err != nil {
+				return nil, err
+			}
+			v.vals[k] = val
+		}
+	}
+	return ast, nil
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := dec.Token()
+		return arr, err
+
+	case json.Delim('{'):
+		dict := NewDict()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			dict.Set(key.(string), val)
+		}
+		_, err := dec.Token()
+		return dict, err
+
+	default:
+		return token, nil
+	}
+}
+
+// RunDecode decodes the code with the decoder, validates the AST, then runs it
+func RunDecode(decoder Decoder, code []byte, ctx *Context) (interface{}, error) {
+	ast, err := decoder.Decode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Validate(ast); err != nil {
+		return nil, err
+	}
+
+	ctx.AST = ast
+	return Run(ctx), nil
+}
+
+// Validate checks if the AST only contains the types that the Decoder contract allows
+func Validate(ast interface{}) error {
+	return validate(ast, []string{})
+}
+
+func validate(ast interface{}, path []string) error {
+	switch v := ast.(type) {
+	case nil, bool, float64, int64, Decimal, string:
+		return nil
+	case []interface{}:
+		for i, el := range v {
+			if err := validate(el, append(path, fmt.Sprint(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for k, el := range v {
+			if err := validate(el, append(path, k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Dict:
+		for _, k := range v.keys {
+			if err := validate(v.vals[k], append(path, k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid ast type %T at path %q", ast, strings.Join(path, "."))
+	}
+}
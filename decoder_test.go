@@ -0,0 +1,98 @@
+package gisp_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/msgpack"
+)
+
+func TestRunDecode(t *testing.T) {
+	sandbox := gisp.New(gisp.Box{
+		"+": func(ctx *gisp.Context) interface{} {
+			return ctx.ArgNum(1) + ctx.ArgNum(2)
+		},
+	})
+
+	for _, decoder := range []gisp.Decoder{
+		gisp.DJSON,
+		gisp.JSON5,
+		gisp.JSON{},
+		gisp.JSON{UseNumber: true},
+	} {
+		out, err := gisp.RunDecode(decoder, []byte(`["+", 1, ["+", 2, 3]]`), &gisp.Context{
+			Sandbox: sandbox,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, float64(6), out)
+	}
+}
+
+func TestJSONNumber(t *testing.T) {
+	ast, err := gisp.JSON{
+		UseNumber: true,
+		Number: func(n json.Number) (interface{}, error) {
+			return n.String(), nil
+		},
+	}.Decode([]byte(`[1.50, {"a": 2}]`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"1.50", map[string]interface{}{"a": "2"}}, ast)
+
+	_, err = gisp.JSON{}.Decode([]byte(`[] 1`))
+	assert.EqualError(t, err, "invalid data after the top-level value")
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, gisp.Validate([]interface{}{"a", 1.0, nil, true, map[string]interface{}{}}))
+
+	err := gisp.Validate([]interface{}{"a", map[string]interface{}{"b": []interface{}{1}}})
+	assert.EqualError(t, err, `invalid ast type int at path "1.b.0"`)
+
+	_, err = gisp.RunDecode(gisp.DecoderFunc(func([]byte) (interface{}, error) {
+		return []interface{}{uint8(1)}, nil
+	}), nil, &gisp.Context{})
+	assert.EqualError(t, err, `invalid ast type uint8 at path "0"`)
+}
+
+func TestBinaryOrdered(t *testing.T) {
+	d := gisp.NewDict()
+	d.Set("b", 1.0)
+	d.Set("a", []interface{}{gisp.DictFromMap(map[string]interface{}{"y": 2.0, "x": 3.0})})
+	data, _ := msgpack.Encode(d)
+
+	ast, err := gisp.Binary{Ordered: true}.Decode(data)
+	assert.Nil(t, err)
+	assert.Equal(t, d, ast)
+
+	ast, _ = gisp.Binary{}.Decode(data)
+	assert.Equal(t, d.Map()["b"], ast.(map[string]interface{})["b"])
+}
+
+func TestTextOrdered(t *testing.T) {
+	for _, d := range []gisp.Decoder{
+		gisp.JSON{Ordered: true},
+		gisp.Relaxed{Ordered: true},
+		gisp.SExp{Ordered: true},
+	} {
+		code := `{"b": 1, "a": {"y": 2, "x": 3}}`
+		if _, ok := d.(gisp.SExp); ok {
+			code = `{b 1 a {y 2 x 3}}`
+		}
+
+		ast, err := d.Decode([]byte(code))
+		assert.Nil(t, err)
+		dict := ast.(*gisp.Dict)
+		assert.Equal(t, []string{"b", "a"}, dict.Keys())
+		a, _ := dict.Get("a")
+		assert.Equal(t, []string{"y", "x"}, a.(*gisp.Dict).Keys())
+	}
+
+	ast, _ := gisp.Relaxed{}.Decode([]byte(`{b: 1}`))
+	assert.Equal(t, map[string]interface{}{"b": 1.0}, ast)
+
+	ast, _ = gisp.SExp{}.Decode([]byte(`{b 1}`))
+	assert.Equal(t, map[string]interface{}{"b": 1.0}, ast)
+}
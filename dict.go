@@ -0,0 +1,108 @@
+package gisp
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Dict a dict that keeps the insertion order of its keys,
+// it's marshaled to json with the same order, so the output is byte-stable
+type Dict struct {
+	keys []string
+	vals map[string]interface{}
+}
+
+// NewDict create an empty dict
+func NewDict() *Dict {
+	return &Dict{vals: map[string]interface{}{}}
+}
+
+// DictFromMap create a dict from the map, the keys are sorted
+func DictFromMap(m map[string]interface{}) *Dict {
+	d := &Dict{
+		keys: make([]string, 0, len(m)),
+		vals: make(map[string]interface{}, len(m)),
+	}
+	for k, v := range m {
+		d.keys = append(d.keys, k)
+		d.vals[k] = v
+	}
+	sort.Strings(d.keys)
+	return d
+}
+
+// Get get the value of the key
+func (d *Dict) Get(key string) (interface{}, bool) {
+	val, has := d.vals[key]
+	return val, has
+}
+
+// Set set the value of the key, a new key will be appended to the end
+func (d *Dict) Set(key string, val interface{}) {
+	if _, has := d.vals[key]; !has {
+		d.keys = append(d.keys, key)
+	}
+	d.vals[key] = val
+}
+
+// Del delete the key
+func (d *Dict) Del(key string) {
+	if _, has := d.vals[key]; !has {
+		return
+	}
+	delete(d.vals, key)
+
+	for i, k := range d.keys {
+		if k == key {
+			d.keys = append(d.keys[:i:i], d.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// Len the count of the keys
+func (d *Dict) Len() int {
+	return len(d.keys)
+}
+
+// Keys returns the keys in order
+func (d *Dict) Keys() []string {
+	return append([]string{}, d.keys...)
+}
+
+// Map returns the values as a plain map
+func (d *Dict) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(d.vals))
+	for k, v := range d.vals {
+		m[k] = v
+	}
+	return m
+}
+
+// MarshalJSON outputs the keys in order. The html characters are not escaped here,
+// the caller's encoder decides whether to escape them.
+func (d *Dict) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+
+	b.WriteByte('{')
+	for i, k := range d.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := enc.Encode(k); err != nil {
+			return nil, err
+		}
+		b.Truncate(b.Len() - 1)
+		b.WriteByte(':')
+
+		if err := enc.Encode(d.vals[k]); err != nil {
+			return nil, err
+		}
+		b.Truncate(b.Len() - 1)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,42 @@
+package gisp_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+)
+
+func TestDict(t *testing.T) {
+	d := gisp.NewDict()
+	d.Set("b", 1.0)
+	d.Set("a", 2.0)
+	d.Set("c", 3.0)
+	d.Set("b", 4.0)
+	d.Del("a")
+	d.Del("x")
+
+	assert.Equal(t, []string{"b", "c"}, d.Keys())
+	assert.Equal(t, 2, d.Len())
+	assert.Equal(t, map[string]interface{}{"b": 4.0, "c": 3.0}, d.Map())
+
+	data, _ := json.Marshal(d)
+	assert.Equal(t, `{"b":4,"c":3}`, string(data))
+
+	assert.Equal(t, []string{"a", "b"}, gisp.DictFromMap(map[string]interface{}{"b": 1, "a": 2}).Keys())
+}
+
+func TestOrderedDecode(t *testing.T) {
+	code := `{"z":1,"a":[{"y":true,"b":null}],"m":"s"}`
+
+	ast, err := gisp.JSON{Ordered: true, UseNumber: true, Number: gisp.ExactNumber}.Decode([]byte(code))
+	assert.Nil(t, err)
+	assert.Nil(t, gisp.Validate(ast))
+
+	v, _ := ast.(*gisp.Dict).Get("z")
+	assert.Equal(t, int64(1), v)
+
+	data, _ := json.Marshal(ast)
+	assert.Equal(t, code, string(data))
+}
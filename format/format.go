@@ -0,0 +1,183 @@
+// Package format implements the canonical layout of gisp json.
+//
+// A call that fits in the line width stays on one line, a long call breaks with
+// the function name on its first line and one argument per line:
+//
+//	["do",
+//	  ["def", "a", 1],
+//	  ["+", ["a"], 1]
+//	]
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Width the max width of a line before it breaks
+var Width = 80
+
+// Indent the indent of each level
+var Indent = "  "
+
+// Source formats the gisp json source, the key order of dicts is kept as it is
+func Source(src []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+
+	n, err := parse(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after the top-level value")
+	}
+
+	var b strings.Builder
+	write(&b, n, 0, 0)
+	b.WriteByte('\n')
+	return []byte(b.String()), nil
+}
+
+type kind int
+
+const (
+	kindScalar kind = iota
+	kindArray
+	kindObject
+)
+
+type node struct {
+	kind kind
+
+	// the encoded scalar
+	text string
+
+	keys     []string
+	children []*node
+}
+
+func parse(dec *json.Decoder) (*node, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('['):
+		n := &node{kind: kindArray}
+		for dec.More() {
+			child, err := parse(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		}
+		_, err := dec.Token()
+		return n, err
+
+	case json.Delim('{'):
+		n := &node{kind: kindObject}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			child, err := parse(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, encode(key))
+			n.children = append(n.children, child)
+		}
+		_, err := dec.Token()
+		return n, err
+
+	default:
+		return &node{kind: kindScalar, text: encode(token)}, nil
+	}
+}
+
+func encode(v interface{}) string {
+	if num, ok := v.(json.Number); ok {
+		return num.String()
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// compact returns the one line form of the node
+func compact(n *node) string {
+	switch n.kind {
+	case kindArray:
+		list := make([]string, len(n.children))
+		for i, child := range n.children {
+			list[i] = compact(child)
+		}
+		return "[" + strings.Join(list, ", ") + "]"
+	case kindObject:
+		list := make([]string, len(n.children))
+		for i, child := range n.children {
+			list[i] = n.keys[i] + ": " + compact(child)
+		}
+		return "{" + strings.Join(list, ", ") + "}"
+	default:
+		return n.text
+	}
+}
+
+// write the node which starts at the col of the line
+func write(b *strings.Builder, n *node, depth, col int) {
+	line := compact(n)
+	if n.kind == kindScalar || len(n.children) == 0 || col+len(line) <= Width {
+		b.WriteString(line)
+		return
+	}
+
+	indent := strings.Repeat(Indent, depth)
+	childIndent := indent + Indent
+
+	if n.kind == kindObject {
+		b.WriteString("{\n")
+		for i, child := range n.children {
+			b.WriteString(childIndent + n.keys[i] + ": ")
+			write(b, child, depth+1, len(childIndent)+len(n.keys[i])+2)
+			if i < len(n.children)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "}")
+		return
+	}
+
+	b.WriteByte('[')
+	rest := n.children
+	if rest[0].kind == kindScalar {
+		// the function name stays on the first line
+		b.WriteString(rest[0].text)
+		rest = rest[1:]
+		if len(rest) > 0 {
+			b.WriteByte(',')
+		}
+	}
+	b.WriteByte('\n')
+
+	for i, child := range rest {
+		b.WriteString(childIndent)
+		write(b, child, depth+1, len(childIndent))
+		if i < len(rest)-1 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString(indent + "]")
+}
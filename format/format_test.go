@@ -0,0 +1,55 @@
+package format_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp/format"
+)
+
+func TestShort(t *testing.T) {
+	out, err := format.Source([]byte(`[ "+",1,
+		["*", 2,3.0 ], {"b":1,"a":"<x>"}]`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "[\"+\", 1, [\"*\", 2, 3.0], {\"b\": 1, \"a\": \"<x>\"}]\n", string(out))
+}
+
+func TestLong(t *testing.T) {
+	src := `["do", ["def", "profession", ["|", "debuglog", "holmes", "hotfix_metrics", "hotfix_log"]],
+		["def", "ret", [":"]], ["for", "index", "item", ["profession"], ["set", ["ret"], ["item"], {"commons": "ok"}]],
+		{"z": "a long string that doesn't fit in the line with the key", "a": [[1, 2], "b"]}]`
+
+	out, err := format.Source([]byte(src))
+
+	assert.Nil(t, err)
+	assert.Equal(t, `["do",
+  ["def",
+    "profession",
+    ["|", "debuglog", "holmes", "hotfix_metrics", "hotfix_log"]
+  ],
+  ["def", "ret", [":"]],
+  ["for",
+    "index",
+    "item",
+    ["profession"],
+    ["set", ["ret"], ["item"], {"commons": "ok"}]
+  ],
+  {
+    "z": "a long string that doesn't fit in the line with the key",
+    "a": [[1, 2], "b"]
+  }
+]
+`, string(out))
+
+	again, _ := format.Source(out)
+	assert.Equal(t, string(out), string(again))
+}
+
+func TestErr(t *testing.T) {
+	_, err := format.Source([]byte(`["a",]`))
+	assert.NotNil(t, err)
+
+	_, err = format.Source([]byte(`[] []`))
+	assert.EqualError(t, err, "invalid data after the top-level value")
+}
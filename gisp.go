@@ -2,6 +2,8 @@ package gisp
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 )
 
 // Context context
@@ -30,6 +32,10 @@ type Context struct {
 
 	// Post-hook after each run
 	PostRun func(*Context)
+
+	// The evaluated arguments of the call, Arg returns them instead of evaluating the AST.
+	// It's set by Call.
+	Args []interface{}
 }
 
 // Error ...
@@ -42,6 +48,17 @@ func (e Error) Error() string {
 	return e.Message
 }
 
+// String returns the message and the stack, one "name:index" frame per line
+func (e Error) String() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	b.WriteByte('\n')
+	for i := 0; i+1 < len(e.Stack); i += 2 {
+		fmt.Fprintf(&b, "%v:%v\n", e.Stack[i], e.Stack[i+1])
+	}
+	return b.String()
+}
+
 func (ctx *Context) liftPanic() {
 	if r := recover(); r != nil {
 		err, ok := r.(Error)
@@ -195,3 +195,19 @@ func TestEmptyFn(t *testing.T) {
 		}),
 	})
 }
+
+func TestErrorString(t *testing.T) {
+	defer func() {
+		r := recover()
+		assert.Equal(t, "function \"foo\" is undefined\nfoo:1\n@:0\n", r.(gisp.Error).String())
+	}()
+
+	gisp.RunJSON(`["@", ["foo"]]`, &gisp.Context{
+		IsLiftPanic: true,
+		Sandbox: gisp.New(gisp.Box{
+			"@": func(ctx *gisp.Context) interface{} {
+				return ctx.Arg(1)
+			},
+		}),
+	})
+}
@@ -1,8 +1,14 @@
 module github.com/ysmood/gisp
 
+go 1.21
+
 require (
 	github.com/a8m/djson v0.0.0-20170509170705-c02c5aef757f
-	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/stretchr/testify v1.3.0
 	github.com/yuin/gopher-lua v0.0.0-20181109042959-a0dfe84f6227
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
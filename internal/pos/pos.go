@@ -0,0 +1,29 @@
+// Package pos locates the errors in the source code.
+package pos
+
+import "fmt"
+
+// Error error with position info, the line and column start from 1
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// New returns the error at the byte offset of the src, the column is counted in unicode code points
+func New(src string, offset int, msg string) *Error {
+	line, col := 1, 1
+	for _, r := range src[:offset] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &Error{Message: msg, Line: line, Column: col}
+}
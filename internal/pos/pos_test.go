@@ -0,0 +1,14 @@
+package pos_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp/internal/pos"
+)
+
+func TestNew(t *testing.T) {
+	assert.EqualError(t, pos.New("ab", 0, "x"), "1:1: x")
+	assert.EqualError(t, pos.New("a\nbc", 3, "x"), "2:2: x")
+	assert.EqualError(t, pos.New("é\né", 5, "x"), "2:2: x")
+}
@@ -0,0 +1,320 @@
+// Package json5 decodes the relaxed json that is easier for human to write,
+// it accepts comments, trailing commas, single-quoted strings and unquoted keys.
+// The output uses the same types as djson: nil, bool, float64, string,
+// []interface{} and map[string]interface{}, use Decoder.Dict to keep the key order.
+package json5
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/ysmood/gisp/internal/pos"
+)
+
+// Error decode error with position info
+type Error = pos.Error
+
+// Decode decodes the data into a value with the default options
+func Decode(data []byte) (interface{}, error) {
+	return Decoder{}.Decode(data)
+}
+
+// Decoder the options to decode
+type Decoder struct {
+	// Dict creates the dict from the keys and values in the order of the data,
+	// the objects are map[string]interface{} if it's nil
+	Dict func(keys []string, vals []interface{}) interface{}
+}
+
+// Decode decodes the data into a value
+func (opts Decoder) Decode(data []byte) (interface{}, error) {
+	d := &decoder{Decoder: opts, data: data}
+
+	val, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.skip(); err != nil {
+		return nil, err
+	}
+	if !d.eof() {
+		return nil, d.unexpected()
+	}
+
+	return val, nil
+}
+
+type decoder struct {
+	Decoder
+	data []byte
+	pos  int
+}
+
+func (d *decoder) eof() bool {
+	return d.pos >= len(d.data)
+}
+
+func (d *decoder) error(msg string) error {
+	return pos.New(string(d.data), d.pos, msg)
+}
+
+func (d *decoder) unexpected() error {
+	if d.eof() {
+		return d.error("unexpected end of input")
+	}
+	return d.error("unexpected " + strconv.Quote(string(d.data[d.pos])))
+}
+
+// skip whitespaces and comments
+func (d *decoder) skip() error {
+	for !d.eof() {
+		switch c := d.data[d.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			d.pos++
+		case bytes.HasPrefix(d.data[d.pos:], []byte("//")):
+			for !d.eof() && d.data[d.pos] != '\n' {
+				d.pos++
+			}
+		case bytes.HasPrefix(d.data[d.pos:], []byte("/*")):
+			end := bytes.Index(d.data[d.pos+2:], []byte("*/"))
+			if end < 0 {
+				return d.error("unterminated comment")
+			}
+			d.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (d *decoder) value() (interface{}, error) {
+	if err := d.skip(); err != nil {
+		return nil, err
+	}
+	if d.eof() {
+		return nil, d.unexpected()
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == '[':
+		return d.array()
+	case c == '{':
+		return d.object()
+	case c == '"' || c == '\'':
+		return d.str()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return d.number()
+	default:
+		switch d.ident() {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, d.unexpected()
+	}
+}
+
+func (d *decoder) array() (interface{}, error) {
+	d.pos++
+	arr := []interface{}{}
+
+	for {
+		if err := d.skip(); err != nil {
+			return nil, err
+		}
+		if !d.eof() && d.data[d.pos] == ']' {
+			d.pos++
+			return arr, nil
+		}
+
+		val, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, val)
+
+		if err := d.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *decoder) object() (interface{}, error) {
+	d.pos++
+	keys := []string{}
+	vals := []interface{}{}
+
+	for {
+		if err := d.skip(); err != nil {
+			return nil, err
+		}
+		if !d.eof() && d.data[d.pos] == '}' {
+			d.pos++
+			return d.newObject(keys, vals), nil
+		}
+
+		key, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := d.skip(); err != nil {
+			return nil, err
+		}
+		if d.eof() || d.data[d.pos] != ':' {
+			return nil, d.unexpected()
+		}
+		d.pos++
+
+		val, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		keys, vals = append(keys, key), append(vals, val)
+
+		if err := d.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *decoder) newObject(keys []string, vals []interface{}) interface{} {
+	if d.Dict != nil {
+		return d.Dict(keys, vals)
+	}
+
+	obj := make(map[string]interface{}, len(keys))
+	for i, k := range keys {
+		obj[k] = vals[i]
+	}
+	return obj
+}
+
+// separator consumes the comma between items, the trailing comma is allowed
+func (d *decoder) separator(end byte) error {
+	if err := d.skip(); err != nil {
+		return err
+	}
+	if d.eof() {
+		return d.unexpected()
+	}
+
+	switch d.data[d.pos] {
+	case ',':
+		d.pos++
+		return nil
+	case end:
+		return nil
+	default:
+		return d.unexpected()
+	}
+}
+
+func (d *decoder) key() (string, error) {
+	if d.eof() {
+		return "", d.unexpected()
+	}
+
+	if c := d.data[d.pos]; c == '"' || c == '\'' {
+		s, err := d.str()
+		if err != nil {
+			return "", err
+		}
+		return s.(string), nil
+	}
+
+	key := d.ident()
+	if key == "" {
+		return "", d.unexpected()
+	}
+	return key, nil
+}
+
+func (d *decoder) ident() string {
+	start := d.pos
+	for !d.eof() {
+		c := d.data[d.pos]
+		if c == '_' || c == '$' || c >= 0x80 ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(d.pos > start && c >= '0' && c <= '9') {
+			d.pos++
+			continue
+		}
+		break
+	}
+	return string(d.data[start:d.pos])
+}
+
+// str converts the string to double-quoted json string then decodes it
+func (d *decoder) str() (interface{}, error) {
+	start := d.pos
+	quote := d.data[d.pos]
+	d.pos++
+
+	var b bytes.Buffer
+	b.WriteByte('"')
+
+	for !d.eof() {
+		c := d.data[d.pos]
+		switch {
+		case c == quote:
+			d.pos++
+			b.WriteByte('"')
+
+			var s string
+			if err := json.Unmarshal(b.Bytes(), &s); err != nil {
+				d.pos = start
+				return nil, d.error("invalid string")
+			}
+			return s, nil
+		case c == '\\' && d.pos+1 < len(d.data):
+			if d.data[d.pos+1] == '\'' {
+				b.WriteByte('\'')
+			} else {
+				b.Write(d.data[d.pos : d.pos+2])
+			}
+			d.pos += 2
+		case c == '"':
+			b.WriteString(`\"`)
+			d.pos++
+		default:
+			b.WriteByte(c)
+			d.pos++
+		}
+	}
+
+	d.pos = start
+	return nil, d.error("unterminated string")
+}
+
+func (d *decoder) number() (interface{}, error) {
+	start := d.pos
+	for !d.eof() {
+		c := d.data[d.pos]
+		if c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E' || (c >= '0' && c <= '9') {
+			d.pos++
+			continue
+		}
+		break
+	}
+
+	token := d.data[start:d.pos]
+	var n json.Number
+	if err := json.Unmarshal(token, &n); err != nil {
+		d.pos = start
+		return nil, d.error("invalid number " + strconv.Quote(string(token)))
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		d.pos = start
+		return nil, d.error("invalid number " + strconv.Quote(string(token)))
+	}
+	return f, nil
+}
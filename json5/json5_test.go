@@ -0,0 +1,49 @@
+package json5_test
+
+import (
+	"testing"
+
+	"github.com/a8m/djson"
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp/json5"
+)
+
+func TestDecode(t *testing.T) {
+	out, err := json5.Decode([]byte(`
+		// the rule of the test
+		["do",
+			/* define the config */
+			["def", 'conf', {
+				rate: 0.5,
+				'mode': 'it\'s "ab"',
+				$name_1: [1, -2e3, true, false, null,],
+			}],
+			["conf"], // trailing comma
+		]
+	`))
+
+	assert.Nil(t, err)
+
+	exp, _ := djson.Decode([]byte(`["do",
+		["def", "conf", {"rate": 0.5, "mode": "it's \"ab\"", "$name_1": [1, -2e3, true, false, null]}],
+		["conf"]
+	]`))
+	assert.Equal(t, exp, out)
+}
+
+func TestDecodeErr(t *testing.T) {
+	_, err := json5.Decode([]byte(`[1, 2`))
+	assert.EqualError(t, err, "1:6: unexpected end of input")
+
+	_, err = json5.Decode([]byte(`{a b}`))
+	assert.EqualError(t, err, `1:4: unexpected "b"`)
+
+	_, err = json5.Decode([]byte("[1] /* x"))
+	assert.EqualError(t, err, "1:5: unterminated comment")
+
+	_, err = json5.Decode([]byte(`[01]`))
+	assert.EqualError(t, err, `1:2: invalid number "01"`)
+
+	_, err = json5.Decode([]byte(`[,]`))
+	assert.EqualError(t, err, `1:2: unexpected ","`)
+}
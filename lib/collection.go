@@ -0,0 +1,285 @@
+package lib
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ysmood/gisp"
+)
+
+// The functions of this file accept a closure created by Fn, or the name of a function
+// in the sandbox. The closure is called with (item key), the key is the index for arrays.
+// The function by name is called with (item) only, so the variadic ones such as "+" work as expected.
+// The dicts are iterated in order, the maps by the sorted keys.
+
+// each iterates the collection, it stops when the fn returns false
+func each(ctx *gisp.Context, coll interface{}, fn func(key, val interface{}) bool) {
+	switch c := coll.(type) {
+	case []interface{}:
+		for i, item := range c {
+			if !fn(float64(i), item) {
+				return
+			}
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(c) {
+			if !fn(k, c[k]) {
+				return
+			}
+		}
+	case *gisp.Dict:
+		for _, k := range c.Keys() {
+			item, _ := c.Get(k)
+			if !fn(k, item) {
+				return
+			}
+		}
+	default:
+		if !reflectEach(coll, fn) {
+			ctx.Error("cannot iterate non-collection type")
+		}
+	}
+}
+
+// isList checks if the collection is an array or a Go slice
+func isList(coll interface{}) bool {
+	if _, ok := coll.([]interface{}); ok {
+		return true
+	}
+	switch indirect(coll).Kind() {
+	case reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// collector builds the result that has the same shape as the collection,
+// arrays for arrays, maps for maps, and dicts for others
+type collector struct {
+	list []interface{}
+	obj  map[string]interface{}
+	dict *gisp.Dict
+}
+
+func newCollector(coll interface{}) *collector {
+	switch {
+	case isList(coll):
+		return &collector{list: []interface{}{}}
+	case isMap(coll):
+		return &collector{obj: map[string]interface{}{}}
+	default:
+		return &collector{dict: gisp.NewDict()}
+	}
+}
+
+func isMap(coll interface{}) bool {
+	_, ok := coll.(map[string]interface{})
+	return ok
+}
+
+func (c *collector) add(key, val interface{}) {
+	switch {
+	case c.list != nil:
+		c.list = append(c.list, val)
+	case c.obj != nil:
+		c.obj[key.(string)] = val
+	default:
+		c.dict.Set(key.(string), val)
+	}
+}
+
+func (c *collector) result() interface{} {
+	switch {
+	case c.list != nil:
+		return c.list
+	case c.obj != nil:
+		return c.obj
+	default:
+		return c.dict
+	}
+}
+
+// apply calls the fn with the args, the key is appended if the fn is not a name
+func apply(ctx *gisp.Context, fn, key interface{}, args ...interface{}) interface{} {
+	if _, ok := fn.(string); !ok {
+		args = append(args, key)
+	}
+	return gisp.Call(ctx, fn, args...)
+}
+
+// test calls the predicate, the result must be bool
+func test(ctx *gisp.Context, fn, key, val interface{}) bool {
+	ret := apply(ctx, fn, key, val)
+	b, ok := ret.(bool)
+	if !ok {
+		ctx.Error(fmt.Sprintf("predicate should return bool, got %T", ret))
+	}
+	return b
+}
+
+// Map (map coll fn) returns the results of the fn for each item,
+// the result has the same shape as the coll
+func Map(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	out := newCollector(coll)
+	each(ctx, coll, func(key, val interface{}) bool {
+		out.add(key, apply(ctx, fn, key, val))
+		return true
+	})
+	return out.result()
+}
+
+// Filter (filter coll fn) keeps the items that the fn returns true
+func Filter(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	out := newCollector(coll)
+	each(ctx, coll, func(key, val interface{}) bool {
+		if test(ctx, fn, key, val) {
+			out.add(key, val)
+		}
+		return true
+	})
+	return out.result()
+}
+
+// Reduce (reduce coll fn init) calls (fn acc item key) for each item, returns the last acc.
+// If the init is omitted the first item is used.
+func Reduce(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	hasAcc := ctx.Len() > 3
+	var acc interface{}
+	if hasAcc {
+		acc = ctx.Arg(3)
+	}
+
+	each(ctx, coll, func(key, val interface{}) bool {
+		if !hasAcc {
+			acc, hasAcc = val, true
+			return true
+		}
+		acc = apply(ctx, fn, key, acc, val)
+		return true
+	})
+	return acc
+}
+
+// Find (find coll fn) returns the first item that the fn returns true, nil if not found
+func Find(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	var found interface{}
+	each(ctx, coll, func(key, val interface{}) bool {
+		if test(ctx, fn, key, val) {
+			found = val
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// FindIndex (find-index coll fn) returns the index or the key of the first item that the fn returns true,
+// -1 for arrays and nil for dicts if not found
+func FindIndex(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	var found interface{}
+	if isList(coll) {
+		found = float64(-1)
+	}
+	each(ctx, coll, func(key, val interface{}) bool {
+		if test(ctx, fn, key, val) {
+			found = key
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// Some (some coll fn) checks if the fn returns true for any item
+func Some(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	ret := false
+	each(ctx, coll, func(key, val interface{}) bool {
+		ret = test(ctx, fn, key, val)
+		return !ret
+	})
+	return ret
+}
+
+// Every (every coll fn) checks if the fn returns true for all items
+func Every(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	ret := true
+	each(ctx, coll, func(key, val interface{}) bool {
+		ret = test(ctx, fn, key, val)
+		return ret
+	})
+	return ret
+}
+
+// FlatMap (flat-map coll fn) maps the items and flattens the array results by one level,
+// it always returns an array
+func FlatMap(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	out := []interface{}{}
+	each(ctx, coll, func(key, val interface{}) bool {
+		ret := apply(ctx, fn, key, val)
+		if arr, ok := ret.([]interface{}); ok {
+			out = append(out, arr...)
+		} else {
+			out = append(out, ret)
+		}
+		return true
+	})
+	return out
+}
+
+// GroupBy (group-by coll fn) groups the items by the string form of the fn results,
+// the groups are in the order of their first items
+func GroupBy(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	out := gisp.NewDict()
+	each(ctx, coll, func(key, val interface{}) bool {
+		group := str(apply(ctx, fn, key, val))
+		list, _ := out.Get(group)
+		arr, _ := list.([]interface{})
+		out.Set(group, append(arr, val))
+		return true
+	})
+	return out
+}
+
+// CountBy (count-by coll fn) counts the items by the string form of the fn results,
+// the counts are in the order of their first items
+func CountBy(ctx *gisp.Context) interface{} {
+	coll := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	out := gisp.NewDict()
+	each(ctx, coll, func(key, val interface{}) bool {
+		group := str(apply(ctx, fn, key, val))
+		count, _ := out.Get(group)
+		n, _ := count.(float64)
+		out.Set(group, n+1)
+		return true
+	})
+	return out
+}
@@ -0,0 +1,78 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func runStd(code string) interface{} {
+	sandbox := gisp.New(lib.Std())
+	sandbox.Set("odd", func(n int) bool { return n%2 == 1 })
+	return gisp.Run(&gisp.Context{AST: decode(code), Sandbox: sandbox})
+}
+
+func dict(code string) interface{} {
+	d, _ := gisp.JSON{Ordered: true}.Decode([]byte(code))
+	return d
+}
+
+func TestMap(t *testing.T) {
+	assert.Equal(t, decode(`[2, 3, 4]`), runStd(`["map", ["|", 1, 2, 3], ["fn", ["x"], ["+", ["x"], 1]]]`))
+	assert.Equal(t, decode(`["a!", "b!"]`), runStd(`["map", ["|", "a", "b"], ["fn", ["x"], ["+", ["x"], "!"]]]`))
+	assert.Equal(t, dict(`{"b": "b1", "a": "a2"}`), runStd(`["map", [":", "b", 1, "a", 2], ["fn", ["v", "k"], ["+", ["k"], ["v"]]]]`))
+	assert.Equal(t, decode(`{"a": 2}`), runStd(`["map", ["$", {"a": 1}], ["fn", ["v"], ["*", ["v"], 2]]]`))
+}
+
+func TestMapByName(t *testing.T) {
+	assert.Equal(t, decode(`["1", "2"]`), runStd(`["map", ["|", 1, 2], "str"]`))
+}
+
+func TestFilter(t *testing.T) {
+	assert.Equal(t, decode(`[1, 3]`), runStd(`["filter", ["|", 1, 2, 3], "odd"]`))
+	assert.Equal(t, dict(`{"c": 3}`), runStd(`["filter", [":", "b", 2, "c", 3], "odd"]`))
+
+	assert.Panics(t, func() { runStd(`["filter", ["|", 1], ["fn", ["x"], 1]]`) })
+}
+
+func TestReduce(t *testing.T) {
+	assert.Equal(t, float64(6), runStd(`["reduce", ["|", 1, 2, 3], "+"]`))
+	assert.Equal(t, float64(16), runStd(`["reduce", ["|", 1, 2, 3], "+", 10]`))
+	assert.Equal(t, "xab", runStd(`["reduce", [":", "a", 1, "b", 2], ["fn", ["acc", "v", "k"], ["+", ["acc"], ["k"]]], "x"]`))
+	assert.Equal(t, nil, runStd(`["reduce", ["|"], "+"]`))
+}
+
+func TestFind(t *testing.T) {
+	assert.Equal(t, float64(3), runStd(`["find", ["|", 2, 3, 5], "odd"]`))
+	assert.Equal(t, nil, runStd(`["find", ["|", 2], "odd"]`))
+	assert.Equal(t, float64(1), runStd(`["find-index", ["|", 2, 3, 5], "odd"]`))
+	assert.Equal(t, float64(-1), runStd(`["find-index", ["|", 2], "odd"]`))
+	assert.Equal(t, "b", runStd(`["find-index", [":", "a", 2, "b", 3], "odd"]`))
+	assert.Equal(t, nil, runStd(`["find-index", [":", "a", 2], "odd"]`))
+}
+
+func TestSomeEvery(t *testing.T) {
+	assert.Equal(t, true, runStd(`["some", ["|", 2, 3], "odd"]`))
+	assert.Equal(t, false, runStd(`["some", ["|"], "odd"]`))
+	assert.Equal(t, false, runStd(`["every", ["|", 1, 2], "odd"]`))
+	assert.Equal(t, true, runStd(`["every", [":", "a", 1], "odd"]`))
+}
+
+func TestFlatMap(t *testing.T) {
+	assert.Equal(t, decode(`[1, 1, 2, 2, [3], [3]]`), runStd(`["flat-map", ["|", 1, 2, ["|", 3]], ["fn", ["x"], ["|", ["x"], ["x"]]]]`))
+	assert.Equal(t, decode(`["a", 1]`), runStd(`["flat-map", [":", "a", 1], ["fn", ["v", "k"], ["|", ["k"], ["v"]]]]`))
+}
+
+func TestGroupBy(t *testing.T) {
+	assert.Equal(t, dict(`{"true": [1, 3], "false": [2]}`), runStd(`["group-by", ["|", 1, 2, 3], "odd"]`))
+	assert.Equal(t, dict(`{"true": 2, "false": 1}`), runStd(`["count-by", ["|", 1, 2, 3], "odd"]`))
+}
+
+func TestCollectionGo(t *testing.T) {
+	sandbox := gisp.New(lib.Std())
+	sandbox.Set("list", []int{1, 2, 3})
+	out := gisp.Run(&gisp.Context{AST: decode(`["map", ["list"], ["fn", ["x"], ["*", ["x"], 2]]]`), Sandbox: sandbox})
+	assert.Equal(t, []interface{}{float64(2), float64(4), float64(6)}, out)
+}
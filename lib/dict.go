@@ -0,0 +1,169 @@
+package lib
+
+import "github.com/ysmood/gisp"
+
+// The dicts are read in order, the maps by the sorted keys, so do the Go structs and typed maps.
+// The functions that create dicts return *gisp.Dict, the order of the keys is stable.
+
+// eachEntry iterates the entries of the dict, it reports error if the value is not dict-like
+func eachEntry(ctx *gisp.Context, d interface{}, fn func(key string, val interface{})) {
+	if d == nil || isList(d) {
+		ctx.Error("expect a dict")
+	}
+	each(ctx, d, func(key, val interface{}) bool {
+		fn(key.(string), val)
+		return true
+	})
+}
+
+// Keys (keys dict) returns the keys of the dict
+func Keys(ctx *gisp.Context) interface{} {
+	list := []interface{}{}
+	eachEntry(ctx, ctx.Arg(1), func(key string, _ interface{}) {
+		list = append(list, key)
+	})
+	return list
+}
+
+// Values (values dict) returns the values of the dict
+func Values(ctx *gisp.Context) interface{} {
+	list := []interface{}{}
+	eachEntry(ctx, ctx.Arg(1), func(_ string, val interface{}) {
+		list = append(list, val)
+	})
+	return list
+}
+
+// Entries (entries dict) returns the [key value] pairs of the dict
+func Entries(ctx *gisp.Context) interface{} {
+	list := []interface{}{}
+	eachEntry(ctx, ctx.Arg(1), func(key string, val interface{}) {
+		list = append(list, []interface{}{key, val})
+	})
+	return list
+}
+
+// FromEntries (from-entries arr) creates a dict from the [key value] pairs, the later ones win
+func FromEntries(ctx *gisp.Context) interface{} {
+	arr, ok := ctx.Arg(1).([]interface{})
+	if !ok {
+		ctx.Error("expect an array of [key value]")
+	}
+
+	out := gisp.NewDict()
+	for _, item := range arr {
+		pair, ok := item.([]interface{})
+		if !ok || len(pair) != 2 {
+			ctx.Error("expect an array of [key value]")
+		}
+		out.Set(str(pair[0]), pair[1])
+	}
+	return out
+}
+
+// Merge (merge a b ...) creates a dict that has the entries of all the dicts,
+// the later ones win, nil is skipped
+func Merge(ctx *gisp.Context) interface{} {
+	out := gisp.NewDict()
+	for i := 1; i < ctx.Len(); i++ {
+		d := ctx.Arg(i)
+		if d == nil {
+			continue
+		}
+		eachEntry(ctx, d, func(key string, val interface{}) {
+			out.Set(key, val)
+		})
+	}
+	return out
+}
+
+// DeepMerge (deep-merge a b ...) is like merge, but merges the values that are both dicts recursively.
+// The dicts passed in are not changed.
+func DeepMerge(ctx *gisp.Context) interface{} {
+	var out interface{} = gisp.NewDict()
+	for i := 1; i < ctx.Len(); i++ {
+		d := ctx.Arg(i)
+		if d == nil {
+			continue
+		}
+		out = deepMerge(ctx, out, d)
+	}
+	return out
+}
+
+func deepMerge(ctx *gisp.Context, a, b interface{}) interface{} {
+	out := gisp.NewDict()
+	eachEntry(ctx, a, func(key string, val interface{}) {
+		out.Set(key, val)
+	})
+	eachEntry(ctx, b, func(key string, val interface{}) {
+		if old, has := out.Get(key); has && isDict(old) && isDict(val) {
+			val = deepMerge(ctx, old, val)
+		}
+		out.Set(key, val)
+	})
+	return out
+}
+
+// keySet gets the argument as a set of keys
+func keySet(ctx *gisp.Context, index int) map[string]bool {
+	arr, ok := ctx.Arg(index).([]interface{})
+	if !ok {
+		ctx.Error("expect an array of keys")
+	}
+	set := map[string]bool{}
+	for _, k := range arr {
+		set[str(k)] = true
+	}
+	return set
+}
+
+// Pick (pick dict keys) creates a dict that only has the keys
+func Pick(ctx *gisp.Context) interface{} {
+	d := ctx.Arg(1)
+	keys := keySet(ctx, 2)
+
+	out := gisp.NewDict()
+	eachEntry(ctx, d, func(key string, val interface{}) {
+		if keys[key] {
+			out.Set(key, val)
+		}
+	})
+	return out
+}
+
+// Omit (omit dict keys) creates a dict that doesn't have the keys
+func Omit(ctx *gisp.Context) interface{} {
+	d := ctx.Arg(1)
+	keys := keySet(ctx, 2)
+
+	out := gisp.NewDict()
+	eachEntry(ctx, d, func(key string, val interface{}) {
+		if !keys[key] {
+			out.Set(key, val)
+		}
+	})
+	return out
+}
+
+// HasKey (has-key dict key) checks if the dict has the key
+func HasKey(ctx *gisp.Context) interface{} {
+	d := ctx.Arg(1)
+	if d == nil || isList(d) {
+		ctx.Error("expect a dict")
+	}
+	_, has := getKey(d, str(ctx.Arg(2)))
+	return has
+}
+
+// MapValues (map-values dict fn) creates a dict that has the results of (fn value key)
+func MapValues(ctx *gisp.Context) interface{} {
+	d := ctx.Arg(1)
+	fn := ctx.Arg(2)
+
+	out := gisp.NewDict()
+	eachEntry(ctx, d, func(key string, val interface{}) {
+		out.Set(key, apply(ctx, fn, key, val))
+	})
+	return out
+}
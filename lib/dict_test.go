@@ -0,0 +1,54 @@
+package lib_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeysValues(t *testing.T) {
+	assert.Equal(t, decode(`["b", "a"]`), runStd(`["keys", [":", "b", 1, "a", 2]]`))
+	assert.Equal(t, decode(`["a", "b"]`), runStd(`["keys", ["$", {"b": 1, "a": 2}]]`))
+	assert.Equal(t, decode(`[1, 2]`), runStd(`["values", [":", "b", 1, "a", 2]]`))
+	assert.Equal(t, decode(`[["b", 1], ["a", 2]]`), runStd(`["entries", [":", "b", 1, "a", 2]]`))
+	assert.Equal(t, dict(`{"b": 1, "a": 3}`), runStd(`["from-entries", ["$", [["b", 1], ["a", 2], ["a", 3]]]]`))
+
+	assert.Panics(t, func() { runStd(`["keys", ["|", 1]]`) })
+	assert.Panics(t, func() { runStd(`["from-entries", ["$", [["a"]]]]`) })
+}
+
+func TestMerge(t *testing.T) {
+	out := runStd(`["merge", [":", "a", 1, "b", ["$", {"x": 1}]], null, [":", "b", ["$", {"y": 2}], "c", 3]]`)
+	data, _ := json.Marshal(out)
+	assert.Equal(t, `{"a":1,"b":{"y":2},"c":3}`, string(data))
+
+	out = runStd(`["deep-merge",
+		["$", {"a": 1, "b": {"x": 1, "z": {"m": 1}}}],
+		["$", {"b": {"y": 2, "z": {"n": 2}}, "c": [1]}]
+	]`)
+	data, _ = json.Marshal(out)
+	assert.Equal(t, `{"a":1,"b":{"x":1,"z":{"m":1,"n":2},"y":2},"c":[1]}`, string(data))
+}
+
+func TestDeepMergeNoChange(t *testing.T) {
+	out := runStd(`["do",
+		["def", "a", ["$", {"b": {"x": 1}}]],
+		["deep-merge", ["a"], ["$", {"b": {"y": 2}}]],
+		["a"]
+	]`)
+	assert.Equal(t, decode(`{"b": {"x": 1}}`), out)
+}
+
+func TestPickOmit(t *testing.T) {
+	assert.Equal(t, dict(`{"c": 3, "a": 1}`), runStd(`["pick", [":", "c", 3, "b", 2, "a", 1], ["|", "a", "c", "d"]]`))
+	assert.Equal(t, dict(`{"b": 2}`), runStd(`["omit", [":", "c", 3, "b", 2, "a", 1], ["|", "a", "c"]]`))
+	assert.Equal(t, true, runStd(`["has-key", [":", "a", null], "a"]`))
+	assert.Equal(t, false, runStd(`["has-key", ["$", {}], "a"]`))
+}
+
+func TestMapValues(t *testing.T) {
+	assert.Equal(t, dict(`{"b": "b1", "a": "a2"}`),
+		runStd(`["map-values", [":", "b", 1, "a", 2], ["fn", ["v", "k"], ["+", ["k"], ["v"]]]]`))
+	assert.Equal(t, dict(`{"a": "1"}`), runStd(`["map-values", ["$", {"a": 1}], "str"]`))
+}
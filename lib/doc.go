@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"reflect"
+
+	"github.com/ysmood/gisp"
+)
+
+// the docs of the std entries by the pointer of their functions
+var docs = map[uintptr]string{}
+
+func init() {
+	for _, entries := range stdVersions {
+		for _, e := range entries {
+			docs[reflect.ValueOf(e.Fn).Pointer()] = e.Doc
+		}
+	}
+}
+
+// Doc returns the documentation of the lib function, see StdEntry.Doc.
+// The examples use the names of the nisp js implementation.
+func Doc(fn interface{}) (string, bool) {
+	f, ok := fn.(func(*gisp.Context) interface{})
+	if !ok {
+		return "", false
+	}
+
+	doc, has := docs[reflect.ValueOf(f).Pointer()]
+	return doc, has
+}
@@ -0,0 +1,24 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func TestDoc(t *testing.T) {
+	doc, has := lib.Doc(lib.Add)
+	assert.True(t, has)
+	assert.Equal(t, "(+ a b ...) adds numbers, or concatenates strings if any of them is string", doc)
+
+	doc, _ = lib.Doc(lib.Map)
+	assert.Contains(t, doc, "a fn given by name gets (fn item)")
+
+	_, has = lib.Doc(func(*gisp.Context) interface{} { return nil })
+	assert.False(t, has)
+
+	_, has = lib.Doc("Add")
+	assert.False(t, has)
+}
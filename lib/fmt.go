@@ -0,0 +1,367 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/ysmood/gisp"
+)
+
+// The placeholder of Fmt is "{{path | filter arg ... | ...}}", the path uses the syntax of Get.
+// A quoted path is a literal string, such as {{"{{"}}.
+// The filter arguments are json strings or bare words, bare numbers are numbers.
+
+// Filters the filters that the placeholders of Fmt and FmtHTML can use,
+// add to it to register custom ones. The found is false if the path is not found.
+var Filters = map[string]func(ctx *gisp.Context, val interface{}, found bool, args []interface{}) interface{}{
+	"upper": func(ctx *gisp.Context, val interface{}, _ bool, _ []interface{}) interface{} {
+		return strings.ToUpper(text(val))
+	},
+	"lower": func(ctx *gisp.Context, val interface{}, _ bool, _ []interface{}) interface{} {
+		return strings.ToLower(text(val))
+	},
+	"trim": func(ctx *gisp.Context, val interface{}, _ bool, _ []interface{}) interface{} {
+		return strings.TrimSpace(text(val))
+	},
+	"default": func(ctx *gisp.Context, val interface{}, found bool, args []interface{}) interface{} {
+		if !found || val == nil || val == "" {
+			return filterArg(args, 0, "")
+		}
+		return val
+	},
+	"fixed": func(ctx *gisp.Context, val interface{}, _ bool, args []interface{}) interface{} {
+		return fixed(ctx, "fixed", val, filterPlaces(ctx, args))
+	},
+	"number": func(ctx *gisp.Context, val interface{}, _ bool, args []interface{}) interface{} {
+		places := -1
+		if len(args) > 0 {
+			places = filterPlaces(ctx, args)
+		}
+		return group(fixed(ctx, "number", val, places))
+	},
+	"json": func(ctx *gisp.Context, val interface{}, _ bool, _ []interface{}) interface{} {
+		return toJSON(val)
+	},
+	"escape": func(ctx *gisp.Context, val interface{}, _ bool, _ []interface{}) interface{} {
+		return html.EscapeString(text(val))
+	},
+	"raw": func(ctx *gisp.Context, val interface{}, _ bool, _ []interface{}) interface{} {
+		return val
+	},
+}
+
+// Fmt (fmt template data) replaces the placeholders with the values of the paths in the data,
+// without data the first key of a path is looked up in the current scope
+func Fmt(ctx *gisp.Context) interface{} {
+	return render(ctx, false)
+}
+
+// FmtHTML (fmt-html template data) like Fmt, but escapes the html of the values, the raw filter skips it
+func FmtHTML(ctx *gisp.Context) interface{} {
+	return render(ctx, true)
+}
+
+type placeholder struct {
+	literal bool
+	path    string
+	filters []filterCall
+}
+
+type filterCall struct {
+	name string
+	args []interface{}
+}
+
+func render(ctx *gisp.Context, escape bool) interface{} {
+	tpl := ctx.ArgStr(1)
+
+	var lookup func(paths []interface{}) (interface{}, bool)
+	if ctx.Len() > 2 {
+		data := ctx.Arg(2)
+		lookup = func(paths []interface{}) (interface{}, bool) {
+			return getPath(data, paths)
+		}
+	} else {
+		lookup = func(paths []interface{}) (interface{}, bool) {
+			return scopePath(ctx.Sandbox, paths)
+		}
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(tpl, "{{")
+		if start < 0 {
+			b.WriteString(tpl)
+			break
+		}
+		b.WriteString(tpl[:start])
+
+		end := placeholderEnd(tpl, start+2)
+		if end < 0 {
+			ctx.Error(fmt.Sprintf("unclosed placeholder: %q", tpl[start:]))
+		}
+
+		p, err := parsePlaceholder(tpl[start+2 : end])
+		if err != nil {
+			ctx.Error(fmt.Sprintf("invalid placeholder %q: %s", tpl[start:end+2], err))
+		}
+
+		b.WriteString(p.value(ctx, lookup, escape))
+		checkLen(ctx, b.Len())
+
+		tpl = tpl[end+2:]
+	}
+
+	checkLen(ctx, b.Len())
+	return b.String()
+}
+
+func (p *placeholder) value(
+	ctx *gisp.Context, lookup func([]interface{}) (interface{}, bool), escape bool,
+) string {
+	var val interface{} = p.path
+	found := true
+	if !p.literal {
+		val, found = lookup(toJSONPath(p.path))
+	}
+
+	for _, f := range p.filters {
+		fn, has := Filters[f.name]
+		if !has {
+			ctx.Error(fmt.Sprintf("unknown filter %q", f.name))
+		}
+		if f.name == "raw" || f.name == "escape" {
+			escape = false
+		}
+		val = fn(ctx, val, found, f.args)
+		found = true
+	}
+
+	if escape {
+		return html.EscapeString(text(val))
+	}
+	return text(val)
+}
+
+// placeholderEnd returns the index of the "}}" that closes the placeholder, the ones in strings are skipped
+func placeholderEnd(tpl string, from int) int {
+	for i := from; i < len(tpl); i++ {
+		switch tpl[i] {
+		case '"':
+			i = stringEnd(tpl, i)
+			if i < 0 {
+				return -1
+			}
+		case '}':
+			if strings.HasPrefix(tpl[i:], "}}") {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// stringEnd returns the index of the closing quote of the json string that starts at the index
+func stringEnd(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func parsePlaceholder(src string) (*placeholder, error) {
+	p := &placeholder{}
+	for i, seg := range splitPipes(src) {
+		tokens, err := fields(seg)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("empty segment")
+		}
+
+		if i == 0 {
+			if len(tokens) > 1 {
+				return nil, fmt.Errorf("unexpected %q", tokens[1])
+			}
+			p.path, p.literal = tokens[0].(string), isQuoted(seg)
+			continue
+		}
+
+		name, ok := tokens[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("the filter name must be a word")
+		}
+		p.filters = append(p.filters, filterCall{name, tokens[1:]})
+	}
+	return p, nil
+}
+
+func isQuoted(seg string) bool {
+	return strings.HasPrefix(strings.TrimSpace(seg), `"`)
+}
+
+// splitPipes splits the placeholder by the "|" that is not in strings
+func splitPipes(src string) []string {
+	list := []string{}
+	last := 0
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '"':
+			if i = stringEnd(src, i); i < 0 {
+				return append(list, src[last:])
+			}
+		case '|':
+			list = append(list, src[last:i])
+			last = i + 1
+		}
+	}
+	return append(list, src[last:])
+}
+
+// fields splits the segment by white spaces, the json strings are unquoted and the numbers are parsed
+func fields(seg string) ([]interface{}, error) {
+	list := []interface{}{}
+	for i := 0; i < len(seg); {
+		switch {
+		case seg[i] == ' ' || seg[i] == '\t' || seg[i] == '\n' || seg[i] == '\r':
+			i++
+
+		case seg[i] == '"':
+			end := stringEnd(seg, i)
+			var s string
+			if end < 0 || json.Unmarshal([]byte(seg[i:end+1]), &s) != nil {
+				return nil, fmt.Errorf("invalid string")
+			}
+			list = append(list, s)
+			i = end + 1
+
+		default:
+			start := i
+			for i < len(seg) && !strings.ContainsRune(" \t\n\r\"", rune(seg[i])) {
+				i++
+			}
+			word := seg[start:i]
+			if f, err := strconv.ParseFloat(word, 64); err == nil && len(list) > 0 {
+				list = append(list, f)
+			} else {
+				list = append(list, word)
+			}
+		}
+	}
+	return list, nil
+}
+
+// scopePath looks up the first key of the paths in the sandbox, and the rest in its value
+func scopePath(sandbox *gisp.Sandbox, paths []interface{}) (interface{}, bool) {
+	if len(paths) == 0 {
+		return nil, false
+	}
+
+	name, ok := paths[0].(string)
+	if !ok {
+		name = str(paths[0])
+	}
+
+	val, has := sandbox.Get(name)
+	if !has {
+		return nil, false
+	}
+	if _, isFn := val.(func(*gisp.Context) interface{}); isFn {
+		return nil, false
+	}
+	if len(paths) == 1 {
+		return val, true
+	}
+	return getPath(val, paths[1:])
+}
+
+// text converts the value to the string in the output, nil is empty, arrays and dicts are json
+func text(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return ""
+	case []interface{}, map[string]interface{}, *gisp.Dict:
+		return toJSON(val)
+	default:
+		return str(val)
+	}
+}
+
+func toJSON(val interface{}) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		return str(val)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func filterArg(args []interface{}, i int, defaultVal interface{}) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	return defaultVal
+}
+
+func filterPlaces(ctx *gisp.Context, args []interface{}) int {
+	places, ok := filterArg(args, 0, 0.0).(float64)
+	if !ok || places < 0 || places > 100 {
+		ctx.Error("the decimal places must be a number between 0 and 100")
+	}
+	return int(places)
+}
+
+// fixed formats the number with the decimal places, -1 means the shortest form
+func fixed(ctx *gisp.Context, name string, val interface{}, places int) string {
+	if s, ok := val.(string); ok {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			val = f
+		}
+	}
+	if !gisp.IsNumber(val) {
+		ctx.Error(fmt.Sprintf("the %s filter expects a number, got %s", name, toJSON(val)))
+	}
+
+	if places < 0 {
+		return str(val)
+	}
+	if isExact(val) {
+		return round(toRat(val), places).FloatString(places)
+	}
+	return strconv.FormatFloat(toFloat(val), 'f', places, 64)
+}
+
+// group inserts commas into the integer part of the formatted number, such as 1,234.5
+func group(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+	if strings.ContainsAny(intPart, "eE+InfNa") {
+		return sign + s
+	}
+
+	var b strings.Builder
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String() + frac
+}
@@ -0,0 +1,82 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func TestFmt(t *testing.T) {
+	assert.Equal(t, "Hi Ann, you have 3 items", runStd(
+		`["fmt", "Hi {{user.name}}, you have {{ count }} items", ["$", {"user": {"name": "Ann"}, "count": 3}]]`,
+	))
+	assert.Equal(t, `b, , [1,"b"]`, runStd(`["fmt", "{{list.1}}, {{none}}, {{list}}", ["$", {"list": [1, "b"]}]]`))
+	assert.Equal(t, "{{ | }}", runStd(`["fmt", "{{\"{{\"}} {{\"|\"}} }}", null]`))
+}
+
+func TestFmtScope(t *testing.T) {
+	assert.Equal(t, "Hi Ann, 2", runStd(`["do",
+		["def", "user", [":", "name", "Ann"]],
+		["def", "n", 2],
+		["fmt", "Hi {{user.name}}, {{n}}{{map}}"]
+	]`))
+}
+
+func TestFmtFilters(t *testing.T) {
+	data := `["$", {"name": "ann", "empty": "", "n": 1234567.891, "neg": -1234, "s": "<b>"}]`
+
+	assert.Equal(t, "ANN", runStd(`["fmt", "{{name | upper}}", `+data+`]`))
+	assert.Equal(t, "guest, none, 0", runStd(`["fmt", "{{x | default guest}}, {{empty | default \"none\"}}, {{x | default 0}}", `+data+`]`))
+	assert.Equal(t, "1234567.89, 1,234,567.891, 1,234,568, -1,234", runStd(
+		`["fmt", "{{n | fixed 2}}, {{n | number}}, {{n | number 0}}, {{neg|number}}", `+data+`]`,
+	))
+	assert.Equal(t, "&lt;b&gt; <b>", runStd(`["fmt", "{{s | escape}} {{s}}", `+data+`]`))
+	assert.Equal(t, `"ann"`, runStd(`["fmt", "{{name | json}}", `+data+`]`))
+}
+
+func TestFmtExact(t *testing.T) {
+	d, _ := gisp.NewDecimal("0.25")
+	sandbox := gisp.New(lib.Std())
+	sandbox.Set("a", d)
+	sandbox.Set("b", 1000)
+
+	assert.Equal(t, "0.3 1,000.00", gisp.Run(&gisp.Context{
+		AST:     decode(`["fmt", "{{a | fixed 1}} {{b | number 2}}"]`),
+		Sandbox: sandbox,
+	}))
+}
+
+func TestFmtHTML(t *testing.T) {
+	data := `["$", {"s": "<a href=\"x\">", "name": "ann"}]`
+
+	assert.Equal(t, "&lt;a href=&#34;x&#34;&gt; <a href=\"x\"> &lt;A HREF=&#34;X&#34;&gt;", runStd(
+		`["fmt-html", "{{s}} {{s | raw}} {{s | upper}}", `+data+`]`,
+	))
+	assert.Equal(t, "&lt;a href=&#34;x&#34;&gt;", runStd(`["fmt-html", "{{s | escape}}", `+data+`]`))
+}
+
+func TestFmtCustomFilter(t *testing.T) {
+	lib.Filters["twice"] = func(ctx *gisp.Context, val interface{}, _ bool, _ []interface{}) interface{} {
+		return val.(string) + val.(string)
+	}
+	defer delete(lib.Filters, "twice")
+
+	assert.Equal(t, "abab", runStd(`["fmt", "{{s | twice}}", ["$", {"s": "ab"}]]`))
+}
+
+func TestFmtError(t *testing.T) {
+	message := func(code string) (msg string) {
+		defer func() { msg = recover().(gisp.Error).Message }()
+		runStd(code)
+		return
+	}
+
+	assert.Equal(t, `unclosed placeholder: "{{a"`, message(`["fmt", "x {{a", null]`))
+	assert.Equal(t, `unknown filter "nope"`, message(`["fmt", "{{a | nope}}", null]`))
+	assert.Equal(t, `invalid placeholder "{{a b}}": unexpected "b"`, message(`["fmt", "{{a b}}", null]`))
+	assert.Equal(t, `invalid placeholder "{{a | }}": empty segment`, message(`["fmt", "{{a | }}", null]`))
+	assert.Equal(t, `the fixed filter expects a number, got "x"`, message(`["fmt", "{{a | fixed 2}}", ["$", {"a": "x"}]]`))
+	assert.Equal(t, "the decimal places must be a number between 0 and 100", message(`["fmt", "{{a | fixed x}}", ["$", {"a": 1}]]`))
+}
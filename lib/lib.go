@@ -5,6 +5,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/ysmood/gisp"
 )
@@ -17,6 +18,9 @@ var MaxStringLen = int(1e6)
 
 // Raw ...
 func Raw(ctx *gisp.Context) interface{} {
+	if ctx.Args != nil {
+		return ctx.Arg(1)
+	}
 	return ctx.AST.([]interface{})[1]
 }
 
@@ -32,55 +36,11 @@ func Get(ctx *gisp.Context) interface{} {
 	pathRaw := ctx.Arg(2)
 	defaultVal := ctx.Arg(3)
 
-	paths := toJSONPath(pathRaw)
-
-	l := len(paths)
-
-	if l == 0 {
+	val, has := getPath(obj, toJSONPath(pathRaw))
+	if !has {
 		return defaultVal
 	}
-
-	for i := 0; i < l; i++ {
-		p := paths[i]
-		switch p.(type) {
-		case string:
-			var has bool
-			dict, ok := obj.(map[string]interface{})
-
-			if !ok {
-				return defaultVal
-			}
-
-			obj, has = dict[p.(string)]
-
-			if !has {
-				return defaultVal
-			}
-		case uint64:
-			switch obj.(type) {
-			case []interface{}:
-				arr := obj.([]interface{})
-				if int(p.(uint64)) >= len(arr) {
-					return defaultVal
-				}
-				obj = arr[p.(uint64)]
-			case map[string]interface{}:
-				var has bool
-				dict := obj.(map[string]interface{})
-				index := strconv.FormatUint(p.(uint64), 10)
-				obj, has = dict[index]
-
-				if !has {
-					return defaultVal
-				}
-			default:
-				return defaultVal
-			}
-		default:
-			return defaultVal
-		}
-	}
-	return obj
+	return val
 }
 
 // Set ...
@@ -102,29 +62,29 @@ func Set(ctx *gisp.Context) interface{} {
 	pathLen := len(paths)
 	cur := obj
 	last := pathLen - 1
+
+	// the missing dicts on the path are created as ordered dicts if the path has one
+	_, ordered := obj.(*gisp.Dict)
+
 	for i := 0; i < pathLen; i++ {
 		p = paths[i]
 
 		switch p.(type) {
 		case string:
 			index := p.(string)
-			self := cur.(map[string]interface{})
 			if i == last {
-				self[index] = val
+				setKey(cur, index, val)
 			} else {
-				next := self[index]
+				next, _ := getKey(cur, index)
 
 				switch next.(type) {
 				case map[string]interface{}:
 				case []interface{}:
+				case *gisp.Dict:
+					ordered = true
 				default:
-					if isUint64(paths[i+1]) {
-						next = make([]interface{}, int(paths[i+1].(uint64)+1))
-					} else {
-						next = map[string]interface{}{}
-					}
-
-					self[index] = next
+					next = newContainer(paths[i+1], ordered)
+					setKey(cur, index, next)
 				}
 				cur = next
 			}
@@ -149,36 +109,29 @@ func Set(ctx *gisp.Context) interface{} {
 					switch next.(type) {
 					case map[string]interface{}:
 					case []interface{}:
+					case *gisp.Dict:
+						ordered = true
 					default:
-						if isUint64(paths[i+1]) {
-							next = make([]interface{}, paths[i+1].(uint64)+1)
-						} else {
-							next = map[string]interface{}{}
-						}
-
+						next = newContainer(paths[i+1], ordered)
 						arr[index] = next
 					}
 					cur = next
 				}
-			case map[string]interface{}:
+			case map[string]interface{}, *gisp.Dict:
 				item := strconv.FormatUint(index, 10)
-				self := cur.(map[string]interface{})
 				if i == last {
-					self[item] = val
+					setKey(cur, item, val)
 				} else {
-					next := self[item]
+					next, _ := getKey(cur, item)
 
 					switch next.(type) {
 					case map[string]interface{}:
 					case []interface{}:
+					case *gisp.Dict:
+						ordered = true
 					default:
-						if isUint64(paths[i+1]) {
-							next = make([]interface{}, int(paths[i+1].(uint64)+1))
-						} else {
-							next = map[string]interface{}{}
-						}
-
-						self[item] = next
+						next = newContainer(paths[i+1], ordered)
+						setKey(cur, item, next)
 					}
 					cur = next
 				}
@@ -209,21 +162,19 @@ func Del(ctx *gisp.Context) interface{} {
 		switch p.(type) {
 		case string:
 			var has bool
-			dict, ok := cur.(map[string]interface{})
-
-			if !ok {
+			if !isDict(cur) {
 				return obj
 			}
 
 			key := p.(string)
 
 			if i == last {
-				delete(dict, key)
+				delKey(cur, key)
 				return obj
 			}
 
 			prev, prevKey = cur, key
-			cur, has = dict[key]
+			cur, has = getKey(cur, key)
 
 			if !has {
 				return obj
@@ -255,8 +206,7 @@ func Del(ctx *gisp.Context) interface{} {
 
 					switch prevKey.(type) {
 					case string:
-						prevDict := prev.(map[string]interface{})
-						prevDict[prevKey.(string)] = newArr
+						setKey(prev, prevKey.(string), newArr)
 					case uint64:
 						prevArr := prev.([]interface{})
 						prevArr[prevKey.(uint64)] = newArr
@@ -266,17 +216,16 @@ func Del(ctx *gisp.Context) interface{} {
 				}
 				prev, prevKey = cur, index
 				cur = arr[p.(uint64)]
-			case map[string]interface{}:
+			case map[string]interface{}, *gisp.Dict:
 				var has bool
-				dict := cur.(map[string]interface{})
 				key := strconv.FormatUint(p.(uint64), 10)
 
 				if i == last {
-					delete(dict, key)
+					delKey(cur, key)
 					return obj
 				}
 				prev, prevKey = cur, key
-				cur, has = dict[key]
+				cur, has = getKey(cur, key)
 
 				if !has {
 					return obj
@@ -307,7 +256,7 @@ func Includes(ctx *gisp.Context) interface{} {
 	target := ctx.Arg(2)
 
 	for _, item := range list {
-		if item == target {
+		if equal(item, target) {
 			return true
 		}
 	}
@@ -326,12 +275,13 @@ func Arr(ctx *gisp.Context) interface{} {
 	return arr
 }
 
-// Dict ...
+// Dict creates a *gisp.Dict that keeps the order of the keys.
+// It returned map[string]interface{} before, use Dict.Map to get a map.
 func Dict(ctx *gisp.Context) interface{} {
 	l := ctx.Len() - 1
-	dict := make(map[string]interface{})
+	dict := gisp.NewDict()
 	for i := 1; i < l; i = i + 2 {
-		dict[str(ctx.Arg(i))] = ctx.Arg(i + 1)
+		dict.Set(str(ctx.Arg(i)), ctx.Arg(i+1))
 	}
 
 	return dict
@@ -378,7 +328,7 @@ func Add(ctx *gisp.Context) (ret interface{}) {
 	if l == 1 {
 		ret = float64(0)
 	} else {
-		arg := ctx.Arg(1)
+		arg := gisp.ToNumber(ctx.Arg(1))
 		switch arg.(type) {
 		case string:
 			if l == 2 {
@@ -393,19 +343,21 @@ func Add(ctx *gisp.Context) (ret interface{}) {
 	}
 
 	for i := 2; i < l; i++ {
-		arg := ctx.Arg(i)
+		arg := gisp.ToNumber(ctx.Arg(i))
 
 		switch arg.(type) {
 		case string:
 			if isStr {
 				ret = ret.(string) + arg.(string)
 			} else {
-				ret = f2s(ret) + arg.(string)
+				ret = str(ret) + arg.(string)
 			}
 			isStr = true
-		case float64:
+		case float64, int64, gisp.Decimal:
 			if isStr {
-				ret = ret.(string) + f2s(arg)
+				ret = ret.(string) + str(arg)
+			} else if gisp.IsNumber(ret) {
+				ret = add(ret, arg)
 			} else {
 				ret = ret.(float64) + arg.(float64)
 			}
@@ -426,9 +378,9 @@ func Add(ctx *gisp.Context) (ret interface{}) {
 // Minus (- 10 2 3)
 func Minus(ctx *gisp.Context) interface{} {
 	l := ctx.Len()
-	o := ctx.ArgNum(1)
+	o := argNum(ctx, 1)
 	for i := 2; i < l; i++ {
-		o -= ctx.ArgNum(i)
+		o = sub(o, argNum(ctx, i))
 	}
 	return o
 }
@@ -436,39 +388,55 @@ func Minus(ctx *gisp.Context) interface{} {
 // Multiply (* 1 2 3)
 func Multiply(ctx *gisp.Context) interface{} {
 	l := ctx.Len()
-	o := ctx.ArgNum(1)
+	o := argNum(ctx, 1)
 	for i := 2; i < l; i++ {
-		o *= ctx.ArgNum(i)
+		var err error
+		o, err = mul(o, argNum(ctx, i))
+		if err != nil {
+			ctx.Error(err.Error())
+		}
 	}
 	return o
 }
 
 // Power ...
 func Power(ctx *gisp.Context) interface{} {
-	return math.Pow(ctx.ArgNum(1), ctx.ArgNum(2))
+	o, err := pow(argNum(ctx, 1), argNum(ctx, 2))
+	if err != nil {
+		ctx.Error(err.Error())
+	}
+	return o
 }
 
 // Divide ...
 func Divide(ctx *gisp.Context) interface{} {
 	l := ctx.Len()
-	o := ctx.ArgNum(1)
+	o := argNum(ctx, 1)
 	for i := 2; i < l; i++ {
-		o /= ctx.ArgNum(i)
+		var err error
+		o, err = div(o, argNum(ctx, i))
+		if err != nil {
+			ctx.Error(err.Error())
+		}
 	}
 	return o
 }
 
 // Mod ...
 func Mod(ctx *gisp.Context) interface{} {
-	return math.Mod(ctx.ArgNum(1), ctx.ArgNum(2))
+	o, err := mod(argNum(ctx, 1), argNum(ctx, 2))
+	if err != nil {
+		ctx.Error(err.Error())
+	}
+	return o
 }
 
-// Eq ...
+// Eq numbers are equal if they have the same value, regardless of the types
 func Eq(ctx *gisp.Context) interface{} {
 	l := ctx.Len()
 	last := ctx.Arg(1)
 	for i := 2; i < l; i++ {
-		if last != ctx.Arg(i) {
+		if !equal(last, ctx.Arg(i)) {
 			return false
 		}
 	}
@@ -478,23 +446,23 @@ func Eq(ctx *gisp.Context) interface{} {
 
 // Ne ...
 func Ne(ctx *gisp.Context) interface{} {
-	return ctx.Arg(1) != ctx.Arg(2)
+	return !equal(ctx.Arg(1), ctx.Arg(2))
 }
 
 // Lt ...
 func Lt(ctx *gisp.Context) interface{} {
 	l := ctx.Len()
-	a := ctx.Arg(1)
+	a := gisp.ToNumber(ctx.Arg(1))
 
 	for i := 2; i < l; i++ {
-		b := ctx.Arg(i)
+		b := gisp.ToNumber(ctx.Arg(i))
 		switch a.(type) {
 		case string:
 			if a.(string) >= b.(string) {
 				return false
 			}
-		case float64:
-			if a.(float64) >= b.(float64) {
+		case float64, int64, gisp.Decimal:
+			if cmpNum(a, b) >= 0 {
 				return false
 			}
 		default:
@@ -511,17 +479,17 @@ func Lt(ctx *gisp.Context) interface{} {
 // Le ...
 func Le(ctx *gisp.Context) interface{} {
 	l := ctx.Len()
-	a := ctx.Arg(1)
+	a := gisp.ToNumber(ctx.Arg(1))
 
 	for i := 2; i < l; i++ {
-		b := ctx.Arg(i)
+		b := gisp.ToNumber(ctx.Arg(i))
 		switch a.(type) {
 		case string:
 			if a.(string) > b.(string) {
 				return false
 			}
-		case float64:
-			if a.(float64) > b.(float64) {
+		case float64, int64, gisp.Decimal:
+			if cmpNum(a, b) > 0 {
 				return false
 			}
 		default:
@@ -538,17 +506,17 @@ func Le(ctx *gisp.Context) interface{} {
 // Gt ...
 func Gt(ctx *gisp.Context) interface{} {
 	l := ctx.Len()
-	a := ctx.Arg(1)
+	a := gisp.ToNumber(ctx.Arg(1))
 
 	for i := 2; i < l; i++ {
-		b := ctx.Arg(i)
+		b := gisp.ToNumber(ctx.Arg(i))
 		switch a.(type) {
 		case string:
 			if a.(string) <= b.(string) {
 				return false
 			}
-		case float64:
-			if a.(float64) <= b.(float64) {
+		case float64, int64, gisp.Decimal:
+			if cmpNum(a, b) <= 0 {
 				return false
 			}
 		default:
@@ -565,17 +533,17 @@ func Gt(ctx *gisp.Context) interface{} {
 // Ge ...
 func Ge(ctx *gisp.Context) interface{} {
 	l := ctx.Len()
-	a := ctx.Arg(1)
+	a := gisp.ToNumber(ctx.Arg(1))
 
 	for i := 2; i < l; i++ {
-		b := ctx.Arg(i)
+		b := gisp.ToNumber(ctx.Arg(i))
 		switch a.(type) {
 		case string:
 			if a.(string) < b.(string) {
 				return false
 			}
-		case float64:
-			if a.(float64) < b.(float64) {
+		case float64, int64, gisp.Decimal:
+			if cmpNum(a, b) < 0 {
 				return false
 			}
 		default:
@@ -622,6 +590,7 @@ func Or(ctx *gisp.Context) interface{} {
 
 // Switch ...
 func Switch(ctx *gisp.Context) interface{} {
+	noValues(ctx, "switch")
 
 	if ctx.Len() == 1 {
 		return nil
@@ -679,7 +648,7 @@ func Switch(ctx *gisp.Context) interface{} {
 			PostRun:     ctx.PostRun,
 		})
 		if hasExpr {
-			if itemValue == expr {
+			if equal(itemValue, expr) {
 				return gisp.Run(&gisp.Context{
 					AST:         node[2],
 					Sandbox:     ctx.Sandbox,
@@ -722,6 +691,8 @@ func Switch(ctx *gisp.Context) interface{} {
 // Fn Define a closure.
 // (fn (a b ...) (exp))
 func Fn(ctx *gisp.Context) interface{} {
+	noValues(ctx, "fn")
+
 	return func(this *gisp.Context) interface{} {
 		// count stack
 		node := this
@@ -759,7 +730,11 @@ func Fn(ctx *gisp.Context) interface{} {
 
 // For loop function that works like golang
 // Example: (for i item (arr) (append (list) (item)))
+// The keys of a dict are iterated in order, the keys of a map are sorted.
+// The fields of a Go struct are iterated in order with their json names.
 func For(ctx *gisp.Context) interface{} {
+	noValues(ctx, "for")
+
 	keyName := ctx.ArgStr(1)
 	valName := ctx.ArgStr(2)
 	arr := ctx.Arg(3)
@@ -770,7 +745,7 @@ func For(ctx *gisp.Context) interface{} {
 	switch arr.(type) {
 	case []interface{}:
 		for i, item := range arr.([]interface{}) {
-			closure.Set(keyName, i)
+			closure.Set(keyName, float64(i))
 			closure.Set(valName, item)
 
 			gisp.Run(&gisp.Context{
@@ -785,7 +760,26 @@ func For(ctx *gisp.Context) interface{} {
 		}
 
 	case map[string]interface{}:
-		for i, item := range arr.(map[string]interface{}) {
+		dict := arr.(map[string]interface{})
+		for _, i := range sortedKeys(dict) {
+			closure.Set(keyName, i)
+			closure.Set(valName, dict[i])
+
+			gisp.Run(&gisp.Context{
+				AST:     ast[4],
+				Sandbox: closure,
+				ENV:     ctx.ENV,
+				Parent:  ctx,
+				Index:   ctx.Index,
+				PreRun:  ctx.PreRun,
+				PostRun: ctx.PostRun,
+			})
+		}
+
+	case *gisp.Dict:
+		dict := arr.(*gisp.Dict)
+		for _, i := range dict.Keys() {
+			item, _ := dict.Get(i)
 			closure.Set(keyName, i)
 			closure.Set(valName, item)
 
@@ -801,13 +795,32 @@ func For(ctx *gisp.Context) interface{} {
 		}
 
 	default:
-		ctx.Error("cannot iterate non-collection type")
+		ok := reflectEach(arr, func(key, val interface{}) bool {
+			closure.Set(keyName, key)
+			closure.Set(valName, val)
+
+			gisp.Run(&gisp.Context{
+				AST:     ast[4],
+				Sandbox: closure,
+				ENV:     ctx.ENV,
+				Parent:  ctx,
+				Index:   ctx.Index,
+				PreRun:  ctx.PreRun,
+				PostRun: ctx.PostRun,
+			})
+			return true
+		})
+
+		if !ok {
+			ctx.Error("cannot iterate non-collection type")
+		}
 	}
 
 	return nil
 }
 
-// Len get size of array, map or string.
+// Len get size of array, map or string, the strings are counted in unicode code points.
+// The Go structs, typed slices and typed maps are supported too.
 // If type is not supported return -1.
 func Len(ctx *gisp.Context) interface{} {
 	obj := ctx.Arg(1)
@@ -817,10 +830,15 @@ func Len(ctx *gisp.Context) interface{} {
 		return float64(len(obj.([]interface{})))
 	case map[string]interface{}:
 		return float64(len(obj.(map[string]interface{})))
+	case *gisp.Dict:
+		return float64(obj.(*gisp.Dict).Len())
 	case string:
-		return float64(len(obj.(string)))
+		return float64(utf8.RuneCountInString(obj.(string)))
 	default:
-		return -1
+		if l, ok := reflectLen(obj); ok {
+			return float64(l)
+		}
+		return float64(-1)
 	}
 }
 
@@ -845,7 +863,12 @@ func Concat(ctx *gisp.Context) interface{} {
 
 // Append ...
 func Append(ctx *gisp.Context) interface{} {
-	return append(ctx.ArgArr(1), ctx.Arg(2))
+	arr := ctx.ArgArr(1)
+
+	// copy it, the backing array of arr may be shared with other arrays
+	ret := make([]interface{}, len(arr), len(arr)+1)
+	copy(ret, arr)
+	return append(ret, ctx.Arg(2))
 }
 
 // Split ...
@@ -861,37 +884,100 @@ func Split(ctx *gisp.Context) interface{} {
 	return ret
 }
 
-// Slice ...
+// Slice (slice value start end) slices the string or array, the end is optional.
+// The strings are sliced by unicode code points. The negative indexes count from the end,
+// the out of range indexes are clamped.
 func Slice(ctx *gisp.Context) interface{} {
 	arr := ctx.Arg(1)
 
 	switch arr.(type) {
 	case string:
-		return arr.(string)[int(ctx.ArgNum(2)):int(ctx.ArgNum(3))]
+		runes := []rune(arr.(string))
+		start, end := sliceRange(ctx, len(runes))
+		return string(runes[start:end])
 	case []interface{}:
-		return arr.([]interface{})[int(ctx.ArgNum(2)):int(ctx.ArgNum(3))]
+		list := arr.([]interface{})
+		start, end := sliceRange(ctx, len(list))
+		return list[start:end]
 	default:
 		return nil
 	}
 }
 
-// IndexOf ...
+// sliceRange gets the start and end from the arguments
+func sliceRange(ctx *gisp.Context, l int) (int, int) {
+	start := clampIndex(ctx.ArgNum(2), l)
+	end := l
+	if ctx.Len() > 3 {
+		if v := ctx.Arg(3); v != nil {
+			end = clampIndex(toFloat(gisp.ToNumber(v)), l)
+		}
+	}
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+// clampIndex counts the negative index from the end, and clamps it to [0, l]
+func clampIndex(f float64, l int) int {
+	if f < 0 {
+		f += float64(l)
+	}
+	switch {
+	case f < 0 || math.IsNaN(f):
+		return 0
+	case f > float64(l):
+		return l
+	default:
+		return int(f)
+	}
+}
+
+// IndexOf (indexOf value target from) returns the index of the target in the string or array,
+// -1 if not found. The strings are indexed by unicode code points.
+// The optional from is where the search starts, the negative one counts from the end.
 func IndexOf(ctx *gisp.Context) interface{} {
 	arr := ctx.Arg(1)
 
 	switch arr.(type) {
 	case string:
-		return float64(strings.Index(arr.(string), ctx.ArgStr(2)))
+		s := arr.(string)
+		target := ctx.ArgStr(2)
+
+		from := 0
+		if ctx.Len() > 3 {
+			from = clampIndex(ctx.ArgNum(3), utf8.RuneCountInString(s))
+		}
+
+		// skip the runes before from
+		offset := 0
+		for i := 0; i < from; i++ {
+			_, size := utf8.DecodeRuneInString(s[offset:])
+			offset += size
+		}
+
+		i := strings.Index(s[offset:], target)
+		if i < 0 {
+			return float64(-1)
+		}
+		return float64(from + utf8.RuneCountInString(s[offset:offset+i]))
 	case []interface{}:
 		list := arr.([]interface{})
 		target := ctx.Arg(2)
-		for i, el := range list {
-			if el == target {
+
+		from := 0
+		if ctx.Len() > 3 {
+			from = clampIndex(ctx.ArgNum(3), len(list))
+		}
+
+		for i := from; i < len(list); i++ {
+			if equal(list[i], target) {
 				return float64(i)
 			}
 		}
-		return -1
+		return float64(-1)
 	default:
-		return -1
+		return float64(-1)
 	}
 }
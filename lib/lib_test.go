@@ -1,6 +1,7 @@
 package lib_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/a8m/djson"
@@ -339,7 +340,7 @@ func TestSetObj(t *testing.T) {
 			"set": lib.Set,
 		}),
 	})
-	exp, _ := djson.Decode([]byte(`
+	exp, _ := gisp.JSON{Ordered: true}.Decode([]byte(`
 		{"a": [null, null, "ok"]}
 	`))
 	assert.Equal(t, exp, out)
@@ -354,7 +355,7 @@ func TestSetObjAndNumberKey(t *testing.T) {
 			"set": lib.Set,
 		}),
 	})
-	exp, _ := djson.Decode([]byte(`
+	exp, _ := gisp.JSON{Ordered: true}.Decode([]byte(`
 		{"1": "ok"}
 	`))
 	assert.Equal(t, exp, out)
@@ -372,7 +373,7 @@ func TestSetCircular(t *testing.T) {
 			"set": lib.Set,
 		}),
 	})
-	exp, _ := djson.Decode([]byte(`
+	exp, _ := gisp.JSON{Ordered: true}.Decode([]byte(`
 		{"a": {}}
 	`))
 	assert.Equal(t, exp, out)
@@ -534,6 +535,20 @@ func TestAppend(t *testing.T) {
 	assert.Equal(t, exp, out)
 }
 
+func TestAppendCopy(t *testing.T) {
+	arr := make([]interface{}, 1, 4)
+	sandbox := gisp.New(gisp.Box{
+		"append": lib.Append,
+		"arr":    arr,
+	})
+
+	a, _ := gisp.RunJSON(`["append", ["arr"], "a"]`, &gisp.Context{Sandbox: sandbox})
+	b, _ := gisp.RunJSON(`["append", ["arr"], "b"]`, &gisp.Context{Sandbox: sandbox})
+
+	assert.Equal(t, []interface{}{nil, "a"}, a)
+	assert.Equal(t, []interface{}{nil, "b"}, b)
+}
+
 func TestSplit(t *testing.T) {
 	out, _ := gisp.RunJSON(`
 		["split", "a.b.c", "."]
@@ -604,3 +619,75 @@ func TestSliceArr(t *testing.T) {
 	`))
 	assert.Equal(t, exp, out)
 }
+
+func TestDictOrder(t *testing.T) {
+	out, _ := gisp.RunDecode(gisp.JSON{Ordered: true}, []byte(`["do",
+		["def", "d", [":", "z", 1, "a", 2]],
+		["set", ["d"], "m.y", 3],
+		["set", ["d"], "m.b", 4],
+		["del", ["d"], "a"],
+		["set", ["d"], "src", {"y": 1, "b": 2}],
+		["def", "keys", ["|"]],
+		["for", "k", "v", ["get", ["d"], "m"],
+			["redef", "keys", ["append", ["keys"], ["k"]]]
+		],
+		["|", ["d"], ["keys"]]
+	]`), &gisp.Context{
+		Sandbox: gisp.New(gisp.Box{
+			"do":     lib.Do,
+			":":      lib.Dict,
+			"|":      lib.Arr,
+			"def":    lib.Def,
+			"redef":  lib.Redef,
+			"set":    lib.Set,
+			"get":    lib.Get,
+			"del":    lib.Del,
+			"for":    lib.For,
+			"append": lib.Append,
+		}),
+	})
+
+	data, _ := json.Marshal(out)
+	assert.Equal(t, `[{"z":1,"m":{"y":3,"b":4},"src":{"y":1,"b":2}},["y","b"]]`, string(data))
+}
+
+func TestForMapSorted(t *testing.T) {
+	out, _ := gisp.RunJSON(`["do",
+		["def", "s", ""],
+		["for", "k", "v", {"c": 1, "a": 2, "b": 3},
+			["redef", "s", ["+", ["s"], ["k"]]]
+		],
+		["s"]
+	]`, &gisp.Context{
+		Sandbox: gisp.New(gisp.Box{
+			"do":    lib.Do,
+			"def":   lib.Def,
+			"redef": lib.Redef,
+			"+":     lib.Add,
+			"for":   lib.For,
+		}),
+	})
+
+	assert.Equal(t, "abc", out)
+}
+
+func TestUnicode(t *testing.T) {
+	assert.Equal(t, float64(5), runStd(`["len", "héllo"]`))
+	assert.Equal(t, "hé", runStd(`["slice", "héllo", 0, 2]`))
+	assert.Equal(t, "lo", runStd(`["slice", "héllo", -2]`))
+	assert.Equal(t, "él", runStd(`["slice", "héllo", 1, -2]`))
+	assert.Equal(t, float64(2), runStd(`["indexOf", "héllo", "l"]`))
+	assert.Equal(t, float64(3), runStd(`["indexOf", "héllo", "l", 3]`))
+	assert.Equal(t, float64(3), runStd(`["indexOf", "héllo", "l", -2]`))
+	assert.Equal(t, "l", runStd(`["char-at", "héllo", -2]`))
+}
+
+func TestSliceClamp(t *testing.T) {
+	assert.Equal(t, "héllo", runStd(`["slice", "héllo", -10, 10]`))
+	assert.Equal(t, "", runStd(`["slice", "héllo", 3, 1]`))
+	assert.Equal(t, decode(`[2, 3]`), runStd(`["slice", ["|", 1, 2, 3], -2, 9]`))
+	assert.Equal(t, decode(`[]`), runStd(`["slice", ["|", 1, 2, 3], 5]`))
+	assert.Equal(t, float64(-1), runStd(`["indexOf", ["|", 1, 2], 1, 1]`))
+	assert.Equal(t, float64(-1), runStd(`["indexOf", "abc", "a", 9]`))
+	assert.Equal(t, float64(-1), runStd(`["len", 1]`))
+}
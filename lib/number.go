@@ -0,0 +1,269 @@
+package lib
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/ysmood/gisp"
+)
+
+// DivisionPrecision the decimal places kept when the quotient of exact numbers
+// is not a finite decimal, such as 1 / 3
+var DivisionPrecision = 16
+
+// MaxPowerExp the max exponent for the exact power, larger ones fall back to float64
+var MaxPowerExp = int64(1024)
+
+// MaxExactBits the max bit length of the numerator or denominator of an exact result,
+// it stops * and ** from growing the number without bound
+var MaxExactBits = 1 << 14
+
+var errDivisionByZero = errors.New("division by zero")
+
+var errExactTooLarge = errors.New("exact number is too large")
+
+// checkExact returns the normalized r, or an error if it's larger than MaxExactBits
+func checkExact(r *big.Rat) (interface{}, error) {
+	if r.Num().BitLen() > MaxExactBits || r.Denom().BitLen() > MaxExactBits {
+		return nil, errExactTooLarge
+	}
+	return gisp.NormalizeExact(r), nil
+}
+
+func isExact(v interface{}) bool {
+	switch v.(type) {
+	case int64, gisp.Decimal:
+		return true
+	default:
+		return false
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	switch v.(type) {
+	case int64:
+		return float64(v.(int64))
+	case gisp.Decimal:
+		return v.(gisp.Decimal).Float64()
+	default:
+		return v.(float64)
+	}
+}
+
+func toRat(v interface{}) *big.Rat {
+	switch v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v.(int64))
+	default:
+		return v.(gisp.Decimal).Rat()
+	}
+}
+
+// round r half away from zero to the decimal places
+func round(r *big.Rat, places int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	n := new(big.Int).Mul(r.Num(), scale)
+	q, m := new(big.Int).QuoRem(n, r.Denom(), new(big.Int))
+
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	}
+	return new(big.Rat).SetFrac(q, scale)
+}
+
+// add the a and b, if any of them is float64 the result is float64,
+// else the result is exact
+func add(a, b interface{}) interface{} {
+	if !isExact(a) || !isExact(b) {
+		return toFloat(a) + toFloat(b)
+	}
+
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			if s := x + y; (s > x) == (y > 0) {
+				return s
+			}
+		}
+	}
+	return gisp.NormalizeExact(new(big.Rat).Add(toRat(a), toRat(b)))
+}
+
+func sub(a, b interface{}) interface{} {
+	if !isExact(a) || !isExact(b) {
+		return toFloat(a) - toFloat(b)
+	}
+
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			if s := x - y; (s < x) == (y > 0) {
+				return s
+			}
+		}
+	}
+	return gisp.NormalizeExact(new(big.Rat).Sub(toRat(a), toRat(b)))
+}
+
+func mul(a, b interface{}) (interface{}, error) {
+	if !isExact(a) || !isExact(b) {
+		return toFloat(a) * toFloat(b), nil
+	}
+
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			if x == 0 || y == 0 {
+				return int64(0), nil
+			}
+			if p := x * y; p/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64) {
+				return p, nil
+			}
+		}
+	}
+	return checkExact(new(big.Rat).Mul(toRat(a), toRat(b)))
+}
+
+func div(a, b interface{}) (interface{}, error) {
+	if !isExact(a) || !isExact(b) {
+		return toFloat(a) / toFloat(b), nil
+	}
+
+	y := toRat(b)
+	if y.Sign() == 0 {
+		return nil, errDivisionByZero
+	}
+
+	q := new(big.Rat).Quo(toRat(a), y)
+	if !isFiniteDecimal(q) {
+		q = round(q, DivisionPrecision)
+	}
+	return gisp.NormalizeExact(q), nil
+}
+
+// mod has the same sign as the a, like math.Mod
+func mod(a, b interface{}) (interface{}, error) {
+	if !isExact(a) || !isExact(b) {
+		return math.Mod(toFloat(a), toFloat(b)), nil
+	}
+
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			if y == 0 {
+				return nil, errDivisionByZero
+			}
+			return x % y, nil
+		}
+	}
+
+	x, y := toRat(a), toRat(b)
+	if y.Sign() == 0 {
+		return nil, errDivisionByZero
+	}
+
+	q := new(big.Rat).Quo(x, y)
+	trunc := new(big.Int).Quo(q.Num(), q.Denom())
+	return gisp.NormalizeExact(new(big.Rat).Sub(x, new(big.Rat).Mul(y, new(big.Rat).SetInt(trunc)))), nil
+}
+
+func pow(a, b interface{}) (interface{}, error) {
+	exp, ok := b.(int64)
+	if !isExact(a) || !ok || exp > MaxPowerExp || exp < -MaxPowerExp {
+		return math.Pow(toFloat(a), toFloat(b)), nil
+	}
+
+	x := toRat(a)
+	neg := exp < 0
+	if neg {
+		exp = -exp
+	}
+
+	// estimate the size before the big numbers are built
+	bits := x.Num().BitLen()
+	if d := x.Denom().BitLen(); d > bits {
+		bits = d
+	}
+	if int64(bits-1)*exp > int64(MaxExactBits) {
+		return nil, errExactTooLarge
+	}
+
+	e := big.NewInt(exp)
+	r := new(big.Rat).SetFrac(
+		new(big.Int).Exp(x.Num(), e, nil),
+		new(big.Int).Exp(x.Denom(), e, nil),
+	)
+
+	if neg {
+		if r.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+		r.Inv(r)
+		if !isFiniteDecimal(r) {
+			r = round(r, DivisionPrecision)
+		}
+	}
+	return checkExact(r)
+}
+
+func isFiniteDecimal(r *big.Rat) bool {
+	d := new(big.Int).Set(r.Denom())
+	m := new(big.Int)
+	for _, f := range []*big.Int{big.NewInt(2), big.NewInt(5)} {
+		for {
+			q, rem := new(big.Int).QuoRem(d, f, m)
+			if rem.Sign() != 0 {
+				break
+			}
+			d = q
+		}
+	}
+	return d.Cmp(big.NewInt(1)) == 0
+}
+
+// cmpNum compares two numbers, returns -1, 0 or 1
+func cmpNum(a, b interface{}) int {
+	if isExact(a) && isExact(b) {
+		if x, ok := a.(int64); ok {
+			if y, ok := b.(int64); ok {
+				switch {
+				case x < y:
+					return -1
+				case x > y:
+					return 1
+				default:
+					return 0
+				}
+			}
+		}
+		return toRat(a).Cmp(toRat(b))
+	}
+
+	x, y := toFloat(a), toFloat(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// equal compares numbers by value regardless of their types
+func equal(a, b interface{}) bool {
+	a, b = gisp.ToNumber(a), gisp.ToNumber(b)
+	if gisp.IsNumber(a) && gisp.IsNumber(b) {
+		if math.IsNaN(toFloat(a)) || math.IsNaN(toFloat(b)) {
+			return false
+		}
+		return cmpNum(a, b) == 0
+	}
+	return a == b
+}
+
+// argNum gets the argument as number without converting its type
+func argNum(ctx *gisp.Context, index int) interface{} {
+	arg := gisp.ToNumber(ctx.Arg(index))
+	if gisp.IsNumber(arg) {
+		return arg
+	}
+	return arg.(float64)
+}
@@ -0,0 +1,170 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func runExact(code string) (interface{}, error) {
+	return gisp.RunDecode(gisp.JSON{UseNumber: true, Number: gisp.ExactNumber}, []byte(code), &gisp.Context{
+		IsLiftPanic: true,
+		Sandbox: gisp.New(gisp.Box{
+			"+":   lib.Add,
+			"-":   lib.Minus,
+			"*":   lib.Multiply,
+			"/":   lib.Divide,
+			"%":   lib.Mod,
+			"**":  lib.Power,
+			"==":  lib.Eq,
+			"!=":  lib.Ne,
+			"<":   lib.Lt,
+			">=":  lib.Ge,
+			"str": lib.Str,
+		}),
+	})
+}
+
+func exactStr(t *testing.T, code string) string {
+	out, err := runExact(`["str", ` + code + `]`)
+	assert.Nil(t, err)
+	return out.(string)
+}
+
+func TestExactArithmetic(t *testing.T) {
+	assert.Equal(t, "0.3", exactStr(t, `["+", 0.1, 0.2]`))
+	assert.Equal(t, "9007199254740993", exactStr(t, `["+", 9007199254740992, 1]`))
+	assert.Equal(t, "9223372036854775808", exactStr(t, `["+", 9223372036854775807, 1]`))
+	assert.Equal(t, "-0.1", exactStr(t, `["-", 0.2, 0.3]`))
+	assert.Equal(t, "85070591730234615847396907784232501249", exactStr(t, `["*", 9223372036854775807, 9223372036854775807]`))
+	assert.Equal(t, "0.02", exactStr(t, `["*", 0.1, 0.2]`))
+	assert.Equal(t, "2", exactStr(t, `["/", 8, 2, 2]`))
+	assert.Equal(t, "0.3333333333333333", exactStr(t, `["/", 1, 3]`))
+	assert.Equal(t, "0.6666666666666667", exactStr(t, `["/", 2, 3]`))
+	assert.Equal(t, "0.125", exactStr(t, `["/", 1, 8]`))
+	assert.Equal(t, "-1", exactStr(t, `["%", -7, 3]`))
+	assert.Equal(t, "0.1", exactStr(t, `["%", 1.1, 0.5]`))
+	assert.Equal(t, "1267650600228229401496703205376", exactStr(t, `["**", 2, 100]`))
+	assert.Equal(t, "0.25", exactStr(t, `["**", 2, -2]`))
+	assert.Equal(t, "1.21", exactStr(t, `["**", 1.1, 2]`))
+	assert.Equal(t, "a0.1", exactStr(t, `["+", "a", 0.1]`))
+}
+
+func TestExactMixFloat(t *testing.T) {
+	out, _ := runExact(`["+", 1, ["/", 1, 3]]`)
+	assert.Equal(t, "1.3333333333333333", out.(gisp.Decimal).String())
+
+	out = gisp.Run(&gisp.Context{
+		AST: []interface{}{"+", int64(1), 0.5},
+		Sandbox: gisp.New(gisp.Box{
+			"+": lib.Add,
+		}),
+	})
+	assert.Equal(t, 1.5, out)
+}
+
+func TestExactDivisionByZero(t *testing.T) {
+	defer func() {
+		r := recover()
+		assert.Equal(t, "division by zero", r.(gisp.Error).Message)
+	}()
+
+	_, _ = runExact(`["/", 1, 0]`)
+}
+
+func TestExactTooLarge(t *testing.T) {
+	old := lib.MaxExactBits
+	lib.MaxExactBits = 256
+	defer func() { lib.MaxExactBits = old }()
+
+	check := func(code string) {
+		defer func() {
+			r := recover()
+			assert.Equal(t, "exact number is too large", r.(gisp.Error).Message)
+		}()
+		_, _ = runExact(code)
+	}
+
+	check(`["**", 3, 200]`)
+	check(`["**", 0.3, 200]`)
+	check(`["*", ["**", 3, 100], ["**", 3, 100], ["**", 3, 100]]`)
+
+	assert.Equal(t, "515377520732011331036461129765621272702107522001", exactStr(t, `["**", 3, 100]`))
+}
+
+func TestExactCompare(t *testing.T) {
+	out, _ := runExact(`["==", ["+", 0.1, 0.2], 0.3]`)
+	assert.Equal(t, true, out)
+
+	out, _ = runExact(`["!=", 9007199254740993, 9007199254740992]`)
+	assert.Equal(t, true, out)
+
+	out, _ = runExact(`["<", 0.1, 1, 9223372036854775808]`)
+	assert.Equal(t, true, out)
+
+	out, _ = runExact(`[">=", 2, 2.0, 1.5]`)
+	assert.Equal(t, true, out)
+
+	out = gisp.Run(&gisp.Context{
+		AST: []interface{}{"==", int64(1), 1.0},
+		Sandbox: gisp.New(gisp.Box{
+			"==": lib.Eq,
+		}),
+	})
+	assert.Equal(t, true, out)
+}
+
+func TestForIndexNumber(t *testing.T) {
+	out, _ := gisp.RunJSON(`["do",
+		["def", "ret", ["|"]],
+		["for", "i", "el", ["arr"],
+			["if", ["==", ["i"], 0],
+				["redef", "ret", ["append", ["ret"], ["+", ["i"], 0.5]]],
+				["redef", "ret", ["append", ["ret"], ["+", ["i"], ["n"]]]]
+			]
+		],
+		["ret"]
+	]`, &gisp.Context{
+		Sandbox: gisp.New(gisp.Box{
+			"do":     lib.Do,
+			"|":      lib.Arr,
+			"def":    lib.Def,
+			"redef":  lib.Redef,
+			"+":      lib.Add,
+			"==":     lib.Eq,
+			"if":     lib.If,
+			"for":    lib.For,
+			"append": lib.Append,
+
+			"arr": []interface{}{"a", "b"},
+			"n":   int32(1),
+		}),
+	})
+
+	assert.Equal(t, []interface{}{0.5, float64(2)}, out)
+}
+
+func TestHostNumber(t *testing.T) {
+	sandbox := gisp.New(gisp.Box{
+		"==":  lib.Eq,
+		"<":   lib.Lt,
+		"str": lib.Str,
+		"int": func(ctx *gisp.Context) interface{} {
+			return 3
+		},
+		"a": uint(3),
+		"b": uint64(1 << 63),
+		"c": float32(0.5),
+	})
+
+	run := func(code string) interface{} {
+		out, _ := gisp.RunJSON(code, &gisp.Context{Sandbox: sandbox})
+		return out
+	}
+
+	assert.Equal(t, true, run(`["==", ["int"], ["a"], 3]`))
+	assert.Equal(t, true, run(`["<", ["c"], ["int"], ["b"]]`))
+	assert.Equal(t, "9223372036854775808", run(`["str", ["b"]]`))
+}
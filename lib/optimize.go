@@ -0,0 +1,413 @@
+package lib
+
+import (
+	"reflect"
+
+	"github.com/ysmood/gisp"
+)
+
+// Optimize returns a smaller AST that has the same result as the ast.
+// The pure is the names of the sandbox functions that have no side effect,
+// calls to them with constant arguments will be folded into their result.
+// It also prunes the unreachable branches of If and Switch, and flattens
+// the nested Do.
+// Only the results of scalar type (nil, bool, number and string) will be folded,
+// so the arrays and dicts created by the AST won't be shared between runs.
+func Optimize(ast interface{}, sandbox *gisp.Sandbox, pure ...string) interface{} {
+	return newOptimizer(sandbox, nil, pure).run(ast)
+}
+
+// PartialEval evaluates the parts of the ast that only depend on the known bindings
+// and the pure functions, returns the residual AST that can be cached and
+// run later with the unknown bindings.
+// Only the known values of scalar type (nil, bool, number and string) will be inlined.
+// The arrays and dicts are not inlined, a run may change them in place and the cached
+// AST would be changed too, so the residual AST keeps reading them by name.
+// To run the residual AST, the sandbox must still have every known binding whose value
+// is an array or a dict, the scalar ones are not needed.
+func PartialEval(ast interface{}, sandbox *gisp.Sandbox, known gisp.Box, pure ...string) interface{} {
+	return newOptimizer(sandbox, known, pure).run(ast)
+}
+
+type optimizer struct {
+	sandbox *gisp.Sandbox
+	pure    map[string]bool
+	known   gisp.Box
+
+	// names defined by the ast itself, such as the ones created by Def or Fn,
+	// their values are unknown before the run
+	bound map[string]bool
+}
+
+func newOptimizer(sandbox *gisp.Sandbox, known gisp.Box, pure []string) *optimizer {
+	o := &optimizer{
+		sandbox: sandbox,
+		pure:    map[string]bool{},
+		known:   gisp.Box{},
+		bound:   map[string]bool{},
+	}
+
+	for _, name := range pure {
+		o.pure[name] = true
+	}
+
+	if len(known) > 0 {
+		o.sandbox = sandbox.Create()
+		for k, v := range known {
+			// normalize the host values the same way as the run does, such as int to int64
+			o.sandbox.Set(k, v)
+			o.known[k], _ = o.sandbox.Get(k)
+		}
+	}
+
+	return o
+}
+
+func (o *optimizer) run(ast interface{}) interface{} {
+	if !o.scan(ast) {
+		// the ast defines names we can't predict, it's not safe to touch it
+		return ast
+	}
+	return o.optimize(ast)
+}
+
+// scan collects the names bound by the ast, returns false if
+// a name is computed at runtime
+func (o *optimizer) scan(node interface{}) bool {
+	ast, ok := node.([]interface{})
+	if !ok || len(ast) == 0 {
+		return true
+	}
+
+	fn, _ := o.lookup(ast[0])
+
+	switch {
+	case isFn(fn, Raw):
+		return true
+	case isFn(fn, Def), isFn(fn, Redef):
+		name, ok := arg(ast, 1).(string)
+		if !ok {
+			return false
+		}
+		o.bound[name] = true
+	case isFn(fn, Fn):
+		args, ok := arg(ast, 1).([]interface{})
+		if !ok {
+			return false
+		}
+		for _, a := range args {
+			name, ok := a.(string)
+			if !ok {
+				return false
+			}
+			o.bound[name] = true
+		}
+	case isFn(fn, For):
+		for i := 1; i <= 2; i++ {
+			name, ok := arg(ast, i).(string)
+			if !ok {
+				return false
+			}
+			o.bound[name] = true
+		}
+	}
+
+	for _, child := range ast {
+		if !o.scan(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup the value of the head of a call, the names bound by the ast are unknown
+func (o *optimizer) lookup(head interface{}) (interface{}, bool) {
+	name, ok := head.(string)
+	if !ok || o.bound[name] {
+		return nil, false
+	}
+	return o.sandbox.Get(name)
+}
+
+func (o *optimizer) optimize(node interface{}) interface{} {
+	ast, ok := node.([]interface{})
+	if !ok || len(ast) == 0 {
+		return node
+	}
+
+	fn, _ := o.lookup(ast[0])
+
+	switch {
+	case isFn(fn, Raw):
+		return node
+	case isFn(fn, If):
+		return o.optimizeIf(ast)
+	case isFn(fn, Switch):
+		return o.optimizeSwitch(ast)
+	case isFn(fn, Do):
+		return o.optimizeDo(ast)
+	}
+
+	if val, ok := o.knownValue(ast[0]); ok {
+		if isScalar(val) {
+			return val
+		}
+		// the arrays and dicts are not inlined, they would be shared between the runs
+		return ast
+	}
+
+	call := make([]interface{}, len(ast))
+	call[0] = o.optimize(ast[0])
+	isConst := true
+	for i := 1; i < len(ast); i++ {
+		call[i] = o.optimize(ast[i])
+		if _, ok := o.constant(call[i]); !ok {
+			isConst = false
+		}
+	}
+
+	if name, ok := ast[0].(string); ok && isConst && o.pure[name] && !o.bound[name] {
+		if val, ok := o.eval(call); ok {
+			return val
+		}
+	}
+
+	return call
+}
+
+func (o *optimizer) optimizeIf(ast []interface{}) interface{} {
+	cond := o.optimize(arg(ast, 1))
+
+	if val, ok := o.constant(cond); ok {
+		if b, ok := val.(bool); ok {
+			if b {
+				return o.optimize(arg(ast, 2))
+			}
+			return o.optimize(arg(ast, 3))
+		}
+	}
+
+	call := []interface{}{ast[0], cond}
+	for i := 2; i < len(ast); i++ {
+		call = append(call, o.optimize(ast[i]))
+	}
+	return call
+}
+
+// optimizeSwitch follows the same rules as Switch to find out the expression,
+// the cases and the default of the ast
+func (o *optimizer) optimizeSwitch(ast []interface{}) interface{} {
+	if len(ast) == 1 {
+		return nil
+	}
+
+	start := 1
+	end := len(ast) - 1
+
+	firstAst, firstOk := ast[start].([]interface{})
+	var expr interface{}
+	hasExpr := false
+
+	if !firstOk {
+		hasExpr = true
+	} else if len(firstAst) == 1 {
+		if name, ok := firstAst[0].(string); !ok || name != "case" {
+			hasExpr = true
+		}
+	}
+
+	call := []interface{}{ast[0]}
+
+	if hasExpr {
+		expr = o.optimize(ast[start])
+		if arr, ok := expr.([]interface{}); ok && len(arr) != 1 {
+			// keep the shape, or it won't be treated as the expression anymore
+			expr = ast[start]
+		}
+		call = append(call, expr)
+		start++
+	}
+	exprVal, exprConst := o.constant(expr)
+
+	var defaultAst interface{}
+	hasDefault := false
+
+	lastAst, lastOk := ast[end].([]interface{})
+	if lastOk && len(lastAst) == 2 {
+		if name, ok := lastAst[0].(string); ok && name == "default" {
+			defaultAst = o.optimize(lastAst[1])
+			hasDefault = true
+			end--
+		}
+	}
+
+	for i := start; i <= end; i++ {
+		node, nodeOk := ast[i].([]interface{})
+		if !nodeOk || len(node) != 3 {
+			// let Switch report the error at runtime
+			return ast
+		}
+		if name, ok := node[0].(string); !ok || name != "case" {
+			return ast
+		}
+
+		caseAst := o.optimize(node[1])
+		bodyAst := o.optimize(node[2])
+
+		matched, decided := false, false
+		if caseVal, ok := o.constant(caseAst); ok {
+			if hasExpr {
+				if exprConst && isScalar(caseVal) && isScalar(exprVal) {
+					matched, decided = equal(caseVal, exprVal), true
+				}
+			} else {
+				b, _ := caseVal.(bool)
+				matched, decided = b, true
+			}
+		}
+
+		if decided && !matched {
+			continue
+		}
+
+		if decided && matched {
+			if (!hasExpr || exprConst) && len(call) == 1+boolToInt(hasExpr) {
+				// no case before it can match
+				return bodyAst
+			}
+			// the cases after it are unreachable
+			return append(call, []interface{}{"case", caseAst, bodyAst})
+		}
+
+		call = append(call, []interface{}{"case", caseAst, bodyAst})
+	}
+
+	if len(call) == 1+boolToInt(hasExpr) && (!hasExpr || exprConst) {
+		return defaultAst
+	}
+
+	if hasDefault {
+		call = append(call, []interface{}{"default", defaultAst})
+	}
+
+	return call
+}
+
+func (o *optimizer) optimizeDo(ast []interface{}) interface{} {
+	list := []interface{}{}
+
+	for i := 1; i < len(ast); i++ {
+		node := o.optimize(ast[i])
+
+		if arr, ok := node.([]interface{}); ok && len(arr) > 0 {
+			if fn, _ := o.lookup(arr[0]); isFn(fn, Do) {
+				list = append(list, arr[1:]...)
+				continue
+			}
+		}
+
+		list = append(list, node)
+	}
+
+	// only the last value is returned, other constants are useless
+	body := []interface{}{}
+	for i, node := range list {
+		if _, ok := o.constant(node); ok && i != len(list)-1 {
+			continue
+		}
+		body = append(body, node)
+	}
+
+	switch len(body) {
+	case 0:
+		return nil
+	case 1:
+		return body[0]
+	default:
+		return append([]interface{}{ast[0]}, body...)
+	}
+}
+
+// knownValue returns the known value that the name refers to
+func (o *optimizer) knownValue(head interface{}) (val interface{}, ok bool) {
+	name, isStr := head.(string)
+	if !isStr || o.bound[name] {
+		return nil, false
+	}
+
+	val, has := o.known[name]
+	if !has {
+		return nil, false
+	}
+
+	switch val.(type) {
+	case func(*gisp.Context) interface{}:
+		return nil, false
+	}
+
+	return val, true
+}
+
+// constant returns the value of the node if it has no dependency on the run
+func (o *optimizer) constant(node interface{}) (interface{}, bool) {
+	switch node.(type) {
+	case []interface{}:
+		ast := node.([]interface{})
+		if len(ast) == 2 {
+			if fn, _ := o.lookup(ast[0]); isFn(fn, Raw) {
+				return ast[1], true
+			}
+		}
+		if len(ast) == 1 {
+			return o.knownValue(ast[0])
+		}
+		return nil, false
+	default:
+		return node, true
+	}
+}
+
+// eval runs the ast, only the scalar results will be returned
+func (o *optimizer) eval(ast interface{}) (val interface{}, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			val, ok = nil, false
+		}
+	}()
+
+	val = gisp.Run(&gisp.Context{
+		AST:         ast,
+		Sandbox:     o.sandbox,
+		IsLiftPanic: true,
+	})
+
+	return val, isScalar(val)
+}
+
+func isScalar(val interface{}) bool {
+	switch val.(type) {
+	case nil, bool, float64, int64, gisp.Decimal, string:
+		return true
+	default:
+		return false
+	}
+}
+
+func isFn(val interface{}, fn func(*gisp.Context) interface{}) bool {
+	f, ok := val.(func(*gisp.Context) interface{})
+	return ok && reflect.ValueOf(f).Pointer() == reflect.ValueOf(fn).Pointer()
+}
+
+func arg(ast []interface{}, index int) interface{} {
+	if index >= len(ast) {
+		return nil
+	}
+	return ast[index]
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,205 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/a8m/djson"
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func optimizeSandbox() *gisp.Sandbox {
+	return gisp.New(gisp.Box{
+		"$":      lib.Raw,
+		"+":      lib.Add,
+		"*":      lib.Multiply,
+		"|":      lib.Arr,
+		"==":     lib.Eq,
+		"if":     lib.If,
+		"do":     lib.Do,
+		"def":    lib.Def,
+		"fn":     lib.Fn,
+		"switch": lib.Switch,
+		"log":    func(ctx *gisp.Context) interface{} { return nil },
+	})
+}
+
+func optimize(code string, pure ...string) interface{} {
+	ast, _ := djson.Decode([]byte(code))
+	return lib.Optimize(ast, optimizeSandbox(), pure...)
+}
+
+func decode(code string) interface{} {
+	ast, _ := djson.Decode([]byte(code))
+	return ast
+}
+
+func TestOptimizeFold(t *testing.T) {
+	out := optimize(`["+", ["a"], ["+", 1, ["*", 2, 3]]]`, "+", "*")
+	assert.Equal(t, decode(`["+", ["a"], 7]`), out)
+}
+
+func TestOptimizeImpure(t *testing.T) {
+	out := optimize(`["+", 1, ["*", 2, 3]]`, "+")
+	assert.Equal(t, decode(`["+", 1, ["*", 2, 3]]`), out)
+}
+
+func TestOptimizeNonScalar(t *testing.T) {
+	out := optimize(`["|", 1, 2]`, "|")
+	assert.Equal(t, decode(`["|", 1, 2]`), out)
+}
+
+func TestOptimizeIf(t *testing.T) {
+	out := optimize(`["if", ["==", 1, 1], ["a"], ["b"]]`, "==")
+	assert.Equal(t, decode(`["a"]`), out)
+
+	out = optimize(`["if", false, ["a"]]`)
+	assert.Equal(t, nil, out)
+
+	out = optimize(`["if", ["c"], ["+", 1, 1], 2]`, "+")
+	assert.Equal(t, decode(`["if", ["c"], 2, 2]`), out)
+}
+
+func TestOptimizeSwitch(t *testing.T) {
+	out := optimize(`["switch", 2,
+		["case", 1, "a"],
+		["case", ["+", 1, 1], "b"],
+		["default", "c"]
+	]`, "+")
+	assert.Equal(t, "b", out)
+
+	out = optimize(`["switch",
+		["case", ["x"], "a"],
+		["case", false, "b"],
+		["case", true, "c"],
+		["case", ["y"], "d"],
+		["default", "e"]
+	]`)
+	assert.Equal(t, decode(`["switch",
+		["case", ["x"], "a"],
+		["case", true, "c"]
+	]`), out)
+
+	out = optimize(`["switch", 3, ["case", 1, "a"]]`)
+	assert.Equal(t, nil, out)
+}
+
+func TestOptimizeDo(t *testing.T) {
+	out := optimize(`["do", ["log"], ["do", 1, ["do", ["log"], 2]], ["a"]]`)
+	assert.Equal(t, decode(`["do", ["log"], ["log"], ["a"]]`), out)
+
+	out = optimize(`["do", 1, ["do", ["a"]]]`)
+	assert.Equal(t, decode(`["a"]`), out)
+}
+
+func TestOptimizeShadow(t *testing.T) {
+	code := `["do", ["def", "+", ["fn", ["a"], 0]], ["+", 1, 2]]`
+	assert.Equal(t, decode(code), optimize(code, "+"))
+}
+
+func TestOptimizeRaw(t *testing.T) {
+	out := optimize(`["+", ["$", ["+", 1, 1]], 1]`, "+")
+	assert.Equal(t, decode(`["+", ["$", ["+", 1, 1]], 1]`), out)
+}
+
+func TestOptimizeSameResult(t *testing.T) {
+	code := `["do",
+		["def", "x", 2],
+		["if", ["==", 1, 2], "no", ["+", ["x"], ["*", 2, 3]]]
+	]`
+	ast := decode(code)
+
+	expected := gisp.Run(&gisp.Context{AST: ast, Sandbox: optimizeSandbox()})
+	out := gisp.Run(&gisp.Context{
+		AST:     lib.Optimize(ast, optimizeSandbox(), "+", "*", "=="),
+		Sandbox: optimizeSandbox(),
+	})
+
+	assert.Equal(t, float64(8), out)
+	assert.Equal(t, expected, out)
+}
+
+func TestPartialEval(t *testing.T) {
+	sandbox := gisp.New(gisp.Box{
+		"$":   lib.Raw,
+		"+":   lib.Add,
+		"get": lib.Get,
+		"if":  lib.If,
+		"==":  lib.Eq,
+	})
+	known := gisp.Box{
+		"config": map[string]interface{}{"rate": float64(2), "mode": "ab"},
+		"items":  []interface{}{float64(1)},
+	}
+
+	ast := decode(`["if", ["==", ["get", ["config"], "mode"], "ab"],
+		["+", ["get", ["req"], "id"], ["get", ["config"], "rate"]],
+		["items"]
+	]`)
+
+	out := lib.PartialEval(ast, sandbox, known, "+", "get", "==")
+	assert.Equal(t, decode(`["+", ["get", ["req"], "id"], 2]`), out)
+
+	req := sandbox.Create()
+	req.Set("req", map[string]interface{}{"id": float64(3)})
+	assert.Equal(t, float64(5), gisp.Run(&gisp.Context{AST: out, Sandbox: req}))
+}
+
+func TestPartialEvalInline(t *testing.T) {
+	sandbox := gisp.New(gisp.Box{
+		"$":   lib.Raw,
+		"get": lib.Get,
+	})
+	known := gisp.Box{
+		"items": []interface{}{float64(1)},
+		"n":     1,
+	}
+
+	// the arrays and dicts are kept as references, so the runs don't share them
+	out := lib.PartialEval(decode(`["get", ["req"], ["items"]]`), sandbox, known, "get")
+	assert.Equal(t, decode(`["get", ["req"], ["items"]]`), out)
+
+	// the host values are normalized before they are inlined
+	out = lib.PartialEval(decode(`["get", ["req"], ["n"]]`), sandbox, known, "get")
+	assert.Equal(t, []interface{}{"get", []interface{}{"req"}, int64(1)}, out)
+	assert.Nil(t, gisp.Validate(out))
+
+	// only the known arrays and dicts need to be supplied again
+	out = lib.PartialEval(decode(`["get", ["items"], ["req"]]`), sandbox, known, "get")
+	run := sandbox.Create()
+	run.Set("items", []interface{}{"a", "b"})
+	run.Set("req", 1)
+	assert.Equal(t, "b", gisp.Run(&gisp.Context{AST: out, Sandbox: run}))
+}
+
+func TestOptimizeCallback(t *testing.T) {
+	count := 0
+	box := lib.Std()
+	box["tick"] = func(ctx *gisp.Context) interface{} {
+		count++
+		return true
+	}
+	sandbox := gisp.New(box)
+
+	// the callbacks can have side effects, so the calls are not folded
+	for _, code := range []string{
+		`["map", ["$", [1, 2]], "tick"]`,
+		`["filter", ["$", [1, 2]], "tick"]`,
+		`["reduce", ["$", [1, 2]], "tick"]`,
+		`["find", ["$", [1, 2]], "tick"]`,
+		`["find-index", ["$", [1, 2]], "tick"]`,
+		`["some", ["$", [1, 2]], "tick"]`,
+		`["every", ["$", [1, 2]], "tick"]`,
+		`["flat-map", ["$", [1, 2]], "tick"]`,
+		`["group-by", ["$", [1, 2]], "tick"]`,
+		`["count-by", ["$", [1, 2]], "tick"]`,
+		`["sort", ["$", [1, 2]], "tick"]`,
+		`["top", ["$", [1, 2]], 1, "tick"]`,
+		`["map-values", ["$", {"a": 1}], "tick"]`,
+	} {
+		ast := decode(code)
+		assert.Equal(t, ast, lib.Optimize(ast, sandbox, lib.StdNames(lib.PureOnly)...))
+	}
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,326 @@
+package lib
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ysmood/gisp"
+)
+
+// The Go values that are not json-shaped, such as structs, pointers to structs,
+// typed slices and typed maps, are read through reflection. The fields of a struct
+// are named by their json tags, the fields tagged "-" and the unexported ones are hidden.
+// The fields of the embedded structs follow the rules of encoding/json: a shallower field
+// hides the deeper ones, and the ambiguous fields at the same depth are dropped unless
+// only one of them is tagged.
+
+type field struct {
+	name   string
+	index  []int
+	tagged bool
+}
+
+// the fields of the struct types
+var fieldsCache sync.Map
+
+func structFields(t reflect.Type) []field {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	// all the candidates in the order of their indexes
+	all := []field{}
+	visiting := map[reflect.Type]bool{}
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		// the embedded pointer can refer to the struct itself
+		if visiting[t] {
+			return
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+
+			name := strings.Split(tag, ",")[0]
+			idx := append(append([]int{}, index...), i)
+
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				collect(ft, idx)
+				continue
+			}
+
+			if f.PkgPath != "" {
+				continue
+			}
+			tagged := name != ""
+			if !tagged {
+				name = f.Name
+			}
+			all = append(all, field{name, idx, tagged})
+		}
+	}
+	collect(t, nil)
+
+	byName := map[string][]field{}
+	for _, f := range all {
+		byName[f.name] = append(byName[f.name], f)
+	}
+
+	list := []field{}
+	for _, f := range all {
+		if d, ok := dominantField(byName[f.name]); ok && slices.Equal(d.index, f.index) {
+			list = append(list, f)
+		}
+	}
+
+	fieldsCache.Store(t, list)
+	return list
+}
+
+// dominantField returns the field that hides the others of the same name, the same as encoding/json
+func dominantField(fields []field) (field, bool) {
+	depth := len(fields[0].index)
+	for _, f := range fields {
+		if len(f.index) < depth {
+			depth = len(f.index)
+		}
+	}
+
+	var shallowest, tagged []field
+	for _, f := range fields {
+		if len(f.index) == depth {
+			shallowest = append(shallowest, f)
+			if f.tagged {
+				tagged = append(tagged, f)
+			}
+		}
+	}
+
+	switch {
+	case len(shallowest) == 1:
+		return shallowest[0], true
+	case len(tagged) == 1:
+		return tagged[0], true
+	default:
+		return field{}, false
+	}
+}
+
+// indirect follows the pointers, the returned value is invalid if a pointer is nil
+func indirect(obj interface{}) reflect.Value {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// fromGo converts the value to the one scripts use, the basic kinds become string, bool
+// or numbers by gisp.ToNumber, so do the named types such as "type ID int"
+func fromGo(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return gisp.ToNumber(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return gisp.ToNumber(v.Interface())
+}
+
+func fieldValue(v reflect.Value, f field) (reflect.Value, bool) {
+	fv, err := v.FieldByIndexErr(f.index)
+	return fv, err == nil
+}
+
+// reflectGet gets the field of a struct, the value of a map or the item of a slice
+func reflectGet(obj interface{}, key string) (interface{}, bool) {
+	v := indirect(obj)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			if f.name == key {
+				fv, ok := fieldValue(v, f)
+				if !ok {
+					return nil, false
+				}
+				return fromGo(fv), true
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		item := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		if item.IsValid() {
+			return fromGo(item), true
+		}
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(key)
+		if err == nil && i >= 0 && i < v.Len() {
+			return fromGo(v.Index(i)), true
+		}
+	}
+	return nil, false
+}
+
+// reflectLen returns the number of the fields, items or entries
+func reflectLen(obj interface{}) (int, bool) {
+	v := indirect(obj)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return len(structFields(v.Type())), true
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// reflectEach iterates the fields in order, the map entries by the sorted keys, or the items,
+// it stops when the fn returns false
+func reflectEach(obj interface{}, fn func(key, val interface{}) bool) bool {
+	v := indirect(obj)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			var val interface{}
+			if fv, ok := fieldValue(v, f); ok {
+				val = fromGo(fv)
+			}
+			if !fn(f.name, val) {
+				break
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return false
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if !fn(k.String(), fromGo(v.MapIndex(k))) {
+				break
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !fn(float64(i), fromGo(v.Index(i))) {
+				break
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// Allowed the methods that scripts can call on the values of a type
+type Allowed struct {
+	typ   reflect.Type
+	names map[string]bool
+}
+
+// Allow allows the named exported methods of the values that have the same type as the sample,
+// a method with a pointer receiver needs a pointer sample, such as Allow(&Req{}, "Header").
+// It panics if a method doesn't exist or gisp.Bind doesn't support it.
+func Allow(sample interface{}, names ...string) Allowed {
+	a := Allowed{typ: reflect.TypeOf(sample), names: map[string]bool{}}
+	for _, name := range names {
+		m, has := a.typ.MethodByName(name)
+		if !has {
+			panic(fmt.Sprintf("lib: %s has no method %q", a.typ, name))
+		}
+		if err := gisp.Bindable(methodType(m.Type)); err != nil {
+			panic(fmt.Sprintf("lib: method %q of %s: %s", name, a.typ, err))
+		}
+		a.names[name] = true
+	}
+	return a
+}
+
+// methodType returns the type of the method value, the receiver is removed from the method type
+func methodType(t reflect.Type) reflect.Type {
+	in := make([]reflect.Type, t.NumIn()-1)
+	for i := range in {
+		in[i] = t.In(i + 1)
+	}
+	out := make([]reflect.Type, t.NumOut())
+	for i := range out {
+		out[i] = t.Out(i)
+	}
+	return reflect.FuncOf(in, out, t.IsVariadic())
+}
+
+// Methods creates a function that calls the allowed methods of the Go values.
+// (call obj "Name" args...)
+// The arguments and the results are converted the same way as gisp.Bind.
+func Methods(allowed ...Allowed) func(*gisp.Context) interface{} {
+	list := map[reflect.Type]map[string]bool{}
+	for _, a := range allowed {
+		if list[a.typ] == nil {
+			list[a.typ] = map[string]bool{}
+		}
+		for name := range a.names {
+			list[a.typ][name] = true
+		}
+	}
+
+	return func(ctx *gisp.Context) interface{} {
+		obj := ctx.Arg(1)
+		name := ctx.ArgStr(2)
+
+		if obj == nil || !list[reflect.TypeOf(obj)][name] {
+			ctx.Error(fmt.Sprintf("method %q of %T is not allowed", name, obj))
+		}
+
+		method := reflect.ValueOf(obj).MethodByName(name)
+
+		// the rest arguments become the arguments of the method
+		ast := ctx.AST.([]interface{})
+		var args []interface{}
+		if ctx.Args != nil && len(ctx.Args) > 2 {
+			args = ctx.Args[2:]
+		}
+		return gisp.Bind(method.Interface())(&gisp.Context{
+			AST:         append([]interface{}{name}, ast[3:]...),
+			Args:        args,
+			Sandbox:     ctx.Sandbox,
+			ENV:         ctx.ENV,
+			Parent:      ctx.Parent,
+			Index:       ctx.Index,
+			IsLiftPanic: ctx.IsLiftPanic,
+			PreRun:      ctx.PreRun,
+			PostRun:     ctx.PostRun,
+		})
+	}
+}
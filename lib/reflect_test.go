@@ -0,0 +1,159 @@
+package lib_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+type Base struct {
+	ID int `json:"id"`
+}
+
+type Req struct {
+	Base
+	Path    string            `json:"path"`
+	Tags    []string          `json:"tags"`
+	Headers map[string]string `json:"headers"`
+	Secret  string            `json:"-"`
+	User    *User
+	private int
+}
+
+type User struct {
+	Name string `json:"name,omitempty"`
+}
+
+func (r *Req) Header(name string) string {
+	return r.Headers[strings.ToLower(name)]
+}
+
+func (r *Req) Pair() (int, int) {
+	return 0, 0
+}
+
+func (r *Req) Drop() {
+	r.Headers = nil
+}
+
+func reflectSandbox(req interface{}) *gisp.Sandbox {
+	box := lib.Std()
+	box["req"] = req
+	box["call"] = lib.Methods(lib.Allow(&Req{}, "Header"))
+	return gisp.New(box)
+}
+
+func runReflect(code string, req interface{}) interface{} {
+	return gisp.Run(&gisp.Context{AST: decode(code), Sandbox: reflectSandbox(req)})
+}
+
+func newReq() *Req {
+	return &Req{
+		Base:    Base{ID: 3},
+		Path:    "/a",
+		Tags:    []string{"x", "y"},
+		Headers: map[string]string{"ua": "go", "accept": "*"},
+		Secret:  "s",
+		User:    &User{Name: "jack"},
+	}
+}
+
+func TestReflectGet(t *testing.T) {
+	req := newReq()
+
+	assert.Equal(t, int64(3), runReflect(`["get", ["req"], "id"]`, req))
+	assert.Equal(t, "/a", runReflect(`["get", ["req"], "path"]`, *req))
+	assert.Equal(t, "y", runReflect(`["get", ["req"], "tags.1"]`, req))
+	assert.Equal(t, "go", runReflect(`["get", ["req"], "headers.ua"]`, req))
+	assert.Equal(t, "jack", runReflect(`["get", ["req"], "User.name"]`, req))
+	assert.Equal(t, "none", runReflect(`["get", ["req"], "Secret", "none"]`, req))
+	assert.Equal(t, "none", runReflect(`["get", ["req"], "private", "none"]`, req))
+	assert.Equal(t, "none", runReflect(`["get", ["req"], "tags.5", "none"]`, req))
+	assert.Equal(t, "none", runReflect(`["get", ["req"], "User.name", "none"]`, &Req{}))
+}
+
+func TestReflectLen(t *testing.T) {
+	req := newReq()
+
+	assert.Equal(t, float64(2), runReflect(`["len", ["get", ["req"], "tags"]]`, req))
+	assert.Equal(t, float64(2), runReflect(`["len", ["get", ["req"], "headers"]]`, req))
+	assert.Equal(t, float64(5), runReflect(`["len", ["req"]]`, req))
+}
+
+func TestReflectFor(t *testing.T) {
+	out := runReflect(`["do",
+		["def", "out", ["|"]],
+		["for", "k", "v", ["get", ["req"], "headers"], ["redef", "out", ["append", ["out"], ["k"]]]],
+		["for", "i", "v", ["get", ["req"], "tags"], ["redef", "out", ["append", ["out"], ["+", ["v"], ["i"]]]]],
+		["for", "k", "v", ["req"], ["redef", "out", ["append", ["out"], ["k"]]]],
+		["out"]
+	]`, newReq())
+
+	assert.Equal(t, []interface{}{"accept", "ua", "x0", "y1", "id", "path", "tags", "headers", "User"}, out)
+}
+
+func TestMethods(t *testing.T) {
+	req := newReq()
+
+	assert.Equal(t, "go", runReflect(`["call", ["req"], "Header", "UA"]`, req))
+
+	func() {
+		defer func() {
+			assert.Equal(t, `method "Drop" of *lib_test.Req is not allowed`, recover().(gisp.Error).Message)
+		}()
+		runReflect(`["call", ["req"], "Drop"]`, req)
+	}()
+
+	assert.Panics(t, func() {
+		runReflect(`["call", ["req"], "Header", "UA"]`, *req)
+	})
+
+	assert.PanicsWithValue(t, `lib: *lib_test.Req has no method "Nope"`, func() { lib.Allow(&Req{}, "Nope") })
+	assert.PanicsWithValue(t, `lib: method "Pair" of *lib_test.Req: Bind doesn't support the results of func() (int, int)`,
+		func() { lib.Allow(&Req{}, "Pair") })
+	assert.PanicsWithValue(t, `lib: lib_test.Req has no method "Header"`, func() { lib.Allow(Req{}, "Header") })
+}
+
+type ID int
+
+type Status string
+
+type A struct {
+	Name  string
+	Level int
+}
+
+type B struct {
+	Name  string
+	Level int `json:"Level"`
+}
+
+type Item struct {
+	A
+	*B
+	ID     ID     `json:"id"`
+	Status Status `json:"status"`
+}
+
+func TestReflectNamedTypes(t *testing.T) {
+	item := Item{ID: 7, Status: "ok", B: &B{Level: 2}}
+
+	assert.Equal(t, int64(7), runReflect(`["get", ["req"], "id"]`, item))
+	assert.Equal(t, "ok!", runReflect(`["+", ["get", ["req"], "status"], "!"]`, item))
+	assert.Equal(t, true, runReflect(`["==", ["get", ["req"], "id"], 7]`, item))
+}
+
+func TestReflectEmbedded(t *testing.T) {
+	item := Item{A: A{Name: "a", Level: 1}, B: &B{Name: "b", Level: 2}}
+
+	// the same keys as encoding/json, the ambiguous Name is dropped
+	data, _ := json.Marshal(item)
+	assert.Equal(t, `{"Level":2,"id":0,"status":""}`, string(data))
+	assert.Equal(t, decode(`["Level", "id", "status"]`), runReflect(`["keys", ["req"]]`, item))
+	assert.Equal(t, int64(2), runReflect(`["get", ["req"], "Level"]`, item))
+	assert.Nil(t, runReflect(`["get", ["req"], "Name"]`, item))
+}
@@ -0,0 +1,245 @@
+package lib
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ysmood/gisp"
+)
+
+// The regexp functions use the RE2 syntax of the Go regexp package,
+// its matching time is linear to the input length.
+
+// MaxPatternLen the max length of a regexp pattern
+var MaxPatternLen = 1000
+
+// MaxRegexpInputLen the max length of the string that a regexp matches against
+var MaxRegexpInputLen = int(1e6)
+
+// MaxRegexpCache the max number of the compiled patterns that are cached
+var MaxRegexpCache = 1000
+
+type patternCache struct {
+	lock  sync.Mutex
+	list  *list.List // the most recently used is at the front
+	items map[string]*list.Element
+}
+
+type regexpItem struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// the compiled patterns shared by all runs
+var regexpCache = &patternCache{list: list.New(), items: map[string]*list.Element{}}
+
+func (c *patternCache) get(pattern string) (*regexp.Regexp, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, has := c.items[pattern]; has {
+		c.list.MoveToFront(el)
+		return el.Value.(*regexpItem).re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.items[pattern] = c.list.PushFront(&regexpItem{pattern, re})
+	for c.list.Len() > MaxRegexpCache && c.list.Len() > 0 {
+		last := c.list.Back()
+		c.list.Remove(last)
+		delete(c.items, last.Value.(*regexpItem).pattern)
+	}
+	return re, nil
+}
+
+// argRegexp gets the input string and the compiled pattern from the arguments
+func argRegexp(ctx *gisp.Context) (string, *regexp.Regexp) {
+	s := ctx.ArgStr(1)
+	pattern := ctx.ArgStr(2)
+
+	if len(pattern) > MaxPatternLen {
+		ctx.Error(fmt.Sprintf("regexp pattern is too long, the max length is %d", MaxPatternLen))
+	}
+	if len(s) > MaxRegexpInputLen {
+		ctx.Error(fmt.Sprintf("regexp input is too long, the max length is %d", MaxRegexpInputLen))
+	}
+
+	re, err := regexpCache.get(pattern)
+	if err != nil {
+		ctx.Error("invalid regexp: " + err.Error())
+	}
+	return s, re
+}
+
+func toList(strs []string) []interface{} {
+	list := make([]interface{}, len(strs))
+	for i, s := range strs {
+		list[i] = s
+	}
+	return list
+}
+
+// argLimit gets the optional max number of the results, -1 means no limit
+func argLimit(ctx *gisp.Context, index int) int {
+	if ctx.Len() > index {
+		return int(ctx.ArgNum(index))
+	}
+	return -1
+}
+
+// ReTest (re-test str pattern) checks if the string matches the pattern
+func ReTest(ctx *gisp.Context) interface{} {
+	s, re := argRegexp(ctx)
+	return re.MatchString(s)
+}
+
+// ReMatch (re-match str pattern) returns the first match and its groups, nil if not matched.
+// The groups that don't participate in the match are empty strings.
+func ReMatch(ctx *gisp.Context) interface{} {
+	s, re := argRegexp(ctx)
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return nil
+	}
+	return toList(m)
+}
+
+// ReFindAll (re-find-all str pattern n) returns all the matches, the optional n limits the number
+func ReFindAll(ctx *gisp.Context) interface{} {
+	s, re := argRegexp(ctx)
+	return toList(re.FindAllString(s, argLimit(ctx, 3)))
+}
+
+// ReReplace (re-replace str pattern repl) replaces all the matches with the repl,
+// the $1 or ${name} in the repl is the group
+func ReReplace(ctx *gisp.Context) interface{} {
+	s, re := argRegexp(ctx)
+	repl := parseRepl(re, ctx.ArgStr(3))
+
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		// check the length before the expanded repl is built
+		checkLen(ctx, b.Len()+m[0]-last+repl.len(m))
+
+		b.WriteString(s[last:m[0]])
+		repl.write(&b, s, m)
+		last = m[1]
+	}
+	checkLen(ctx, b.Len()+len(s)-last)
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+// replTemplate the parsed repl of ReReplace, it follows the syntax of regexp.Regexp.Expand
+type replTemplate []replPart
+
+// replPart is either a literal or a group, the group is -1 for a literal
+type replPart struct {
+	literal string
+	group   int
+}
+
+func parseRepl(re *regexp.Regexp, repl string) replTemplate {
+	t := replTemplate{}
+	for {
+		i := strings.IndexByte(repl, '$')
+		if i < 0 {
+			break
+		}
+		t = append(t, replPart{repl[:i], -1})
+		repl = repl[i+1:]
+
+		if strings.HasPrefix(repl, "$") {
+			t = append(t, replPart{"$", -1})
+			repl = repl[1:]
+			continue
+		}
+
+		name, rest, ok := replName(repl)
+		if !ok {
+			t = append(t, replPart{"$", -1})
+			continue
+		}
+		repl = rest
+
+		group := re.SubexpIndex(name)
+		if n, err := strconv.Atoi(name); err == nil {
+			group = n
+		}
+		if group < 0 || group > re.NumSubexp() {
+			continue
+		}
+		t = append(t, replPart{group: group})
+	}
+	return append(t, replPart{repl, -1})
+}
+
+// replName extracts the name of "name" or "{name}" at the start of the repl
+func replName(repl string) (name, rest string, ok bool) {
+	brace := strings.HasPrefix(repl, "{")
+	if brace {
+		repl = repl[1:]
+	}
+
+	i := 0
+	for i < len(repl) {
+		r, size := utf8.DecodeRuneInString(repl[i:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		return "", "", false
+	}
+	name, rest = repl[:i], repl[i:]
+
+	if brace {
+		if !strings.HasPrefix(rest, "}") {
+			return "", "", false
+		}
+		rest = rest[1:]
+	}
+	return name, rest, true
+}
+
+// len returns the length of the expanded repl for the match
+func (t replTemplate) len(m []int) int {
+	n := 0
+	for _, p := range t {
+		if p.group < 0 {
+			n += len(p.literal)
+		} else if m[2*p.group] >= 0 {
+			n += m[2*p.group+1] - m[2*p.group]
+		}
+	}
+	return n
+}
+
+func (t replTemplate) write(b *strings.Builder, s string, m []int) {
+	for _, p := range t {
+		if p.group < 0 {
+			b.WriteString(p.literal)
+		} else if m[2*p.group] >= 0 {
+			b.WriteString(s[m[2*p.group]:m[2*p.group+1]])
+		}
+	}
+}
+
+// ReSplit (re-split str pattern n) splits the string by the pattern, the optional n limits the number
+func ReSplit(ctx *gisp.Context) interface{} {
+	s, re := argRegexp(ctx)
+	return toList(re.Split(s, argLimit(ctx, 3)))
+}
@@ -0,0 +1,86 @@
+package lib_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func TestReTest(t *testing.T) {
+	assert.Equal(t, true, runStd(`["re-test", "a12", "\\d+"]`))
+	assert.Equal(t, false, runStd(`["re-test", "abc", "^\\d+$"]`))
+}
+
+func TestReMatch(t *testing.T) {
+	assert.Equal(t, decode(`["k=v", "k", "v"]`), runStd(`["re-match", "x k=v", "(\\w)=(\\w)"]`))
+	assert.Nil(t, runStd(`["re-match", "x", "\\d"]`))
+}
+
+func TestReFindAll(t *testing.T) {
+	assert.Equal(t, decode(`["1", "22", "333"]`), runStd(`["re-find-all", "1a22b333", "\\d+"]`))
+	assert.Equal(t, decode(`["1", "22"]`), runStd(`["re-find-all", "1a22b333", "\\d+", 2]`))
+	assert.Equal(t, decode(`[]`), runStd(`["re-find-all", "abc", "\\d+"]`))
+}
+
+func TestReReplace(t *testing.T) {
+	assert.Equal(t, "v=k, b=a", runStd(`["re-replace", "k=v, a=b", "(\\w)=(\\w)", "$2=$1"]`))
+	assert.Equal(t, "[é]", runStd(`["re-replace", "é", "(?P<c>.)", "[${c}]"]`))
+}
+
+func TestReReplaceExpand(t *testing.T) {
+	for _, c := range [][2]string{
+		{`(?P<k>\w)=(\w)`, "$2=$k"},
+		{`(\w)=(\w)`, "${1}x $1x $$ $ ${1 $9 $é"},
+		{`(a)|(b)`, "[$1$2]"},
+		{``, "-"},
+		{`x*`, "-"},
+	} {
+		re := regexp.MustCompile(c[0])
+		code, _ := json.Marshal([]interface{}{"re-replace", "k=v, a=b é", c[0], c[1]})
+		assert.Equal(t, re.ReplaceAllString("k=v, a=b é", c[1]), runStd(string(code)), c)
+	}
+}
+
+func TestReSplit(t *testing.T) {
+	assert.Equal(t, decode(`["a", "b", "c"]`), runStd(`["re-split", "a, b ,c", "\\s*,\\s*"]`))
+	assert.Equal(t, decode(`["a", "b ,c"]`), runStd(`["re-split", "a, b ,c", "\\s*,\\s*", 2]`))
+}
+
+func TestReError(t *testing.T) {
+	message := func(code string) (msg string) {
+		defer func() { msg = recover().(gisp.Error).Message }()
+		runStd(code)
+		return
+	}
+
+	assert.Equal(t, "invalid regexp: error parsing regexp: missing closing ): `(a`", message(`["re-test", "a", "(a"]`))
+	assert.Regexp(t, "^invalid regexp: ", message(`["re-test", "a", "(?=a)"]`))
+
+	oldPattern, oldInput := lib.MaxPatternLen, lib.MaxRegexpInputLen
+	lib.MaxPatternLen, lib.MaxRegexpInputLen = 3, 5
+	defer func() { lib.MaxPatternLen, lib.MaxRegexpInputLen = oldPattern, oldInput }()
+
+	assert.Equal(t, "regexp pattern is too long, the max length is 3", message(`["re-test", "a", "aaaa"]`))
+	assert.Equal(t, "regexp input is too long, the max length is 5", message(`["re-test", "aaaaaa", "a"]`))
+
+	oldLen := lib.MaxStringLen
+	lib.MaxStringLen = 8
+	defer func() { lib.MaxStringLen = oldLen }()
+
+	assert.Equal(t, "max string length exceeded 8", message(`["re-replace", "abc", "", "$$$$"]`))
+	assert.Equal(t, "-a-b-c-", runStd(`["re-replace", "abc", "", "-"]`))
+}
+
+func TestReCache(t *testing.T) {
+	old := lib.MaxRegexpCache
+	lib.MaxRegexpCache = 1
+	defer func() { lib.MaxRegexpCache = old }()
+
+	assert.Equal(t, true, runStd(`["re-test", "a", "a"]`))
+	assert.Equal(t, true, runStd(`["re-test", "b", "b"]`))
+	assert.Equal(t, true, runStd(`["re-test", "a", "a"]`))
+}
@@ -0,0 +1,199 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ysmood/gisp"
+)
+
+// MaxArrayLen the max length of the arrays that sort, uniq and top accept
+var MaxArrayLen = int(1e6)
+
+// argList gets the argument as array and checks the MaxArrayLen
+func argList(ctx *gisp.Context, index int) []interface{} {
+	arr, ok := ctx.Arg(index).([]interface{})
+	if !ok {
+		ctx.Error("expect an array")
+	}
+	if len(arr) > MaxArrayLen {
+		ctx.Error(fmt.Sprintf("array is too long, the max length is %d", MaxArrayLen))
+	}
+	return arr
+}
+
+// rank the order of the types in the natural order
+func rank(val interface{}) int {
+	switch {
+	case val == nil:
+		return 0
+	case isBool(val):
+		return 1
+	case gisp.IsNumber(val):
+		return 2
+	case isString(val):
+		return 3
+	default:
+		return 4
+	}
+}
+
+func isBool(val interface{}) bool {
+	_, ok := val.(bool)
+	return ok
+}
+
+func isString(val interface{}) bool {
+	_, ok := val.(string)
+	return ok
+}
+
+// compare returns the natural order of a and b, nil < bool < number < string < others,
+// the NaN is less than other numbers, the values of other types are equal
+func compare(a, b interface{}) int {
+	a, b = gisp.ToNumber(a), gisp.ToNumber(b)
+
+	ra, rb := rank(a), rank(b)
+	if ra != rb {
+		return ra - rb
+	}
+
+	switch ra {
+	case 1:
+		x, y := a.(bool), b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case y:
+			return -1
+		default:
+			return 1
+		}
+	case 2:
+		xNaN, yNaN := math.IsNaN(toFloat(a)), math.IsNaN(toFloat(b))
+		switch {
+		case xNaN && yNaN:
+			return 0
+		case xNaN:
+			return -1
+		case yNaN:
+			return 1
+		}
+		return cmpNum(a, b)
+	case 3:
+		x, y := a.(string), b.(string)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// comparator returns the compare function of the argument, the argument can be
+// nil for the natural order, a key path such as "user.age" to compare the values of the path
+// by the natural order, or a function that returns a number, negative means a is before b
+func comparator(ctx *gisp.Context, index int) func(a, b interface{}) int {
+	if ctx.Len() <= index {
+		return compare
+	}
+
+	by := ctx.Arg(index)
+	switch by.(type) {
+	case nil:
+		return compare
+	case string, float64, int64, []interface{}:
+		paths := toJSONPath(by)
+		return func(a, b interface{}) int {
+			x, _ := getPath(a, paths)
+			y, _ := getPath(b, paths)
+			return compare(x, y)
+		}
+	}
+
+	return func(a, b interface{}) int {
+		ret := gisp.ToNumber(gisp.Call(ctx, by, a, b))
+		if !gisp.IsNumber(ret) {
+			ctx.Error(fmt.Sprintf("comparator should return number, got %T", ret))
+		}
+		return cmpNum(ret, int64(0))
+	}
+}
+
+func sorted(arr []interface{}, cmp func(a, b interface{}) int) []interface{} {
+	list := append([]interface{}{}, arr...)
+	sort.SliceStable(list, func(i, j int) bool {
+		return cmp(list[i], list[j]) < 0
+	})
+	return list
+}
+
+// Sort (sort arr by) returns a new array that is stably sorted.
+// The by is optional, it can be a key path such as "user.age", or a comparator (fn (a b) number).
+// Without the by it sorts in the natural order: nil < bool < number < string < others.
+func Sort(ctx *gisp.Context) interface{} {
+	return sorted(argList(ctx, 1), comparator(ctx, 2))
+}
+
+// Reverse (reverse arr) returns a new array in reverse order
+func Reverse(ctx *gisp.Context) interface{} {
+	arr := argList(ctx, 1)
+	list := make([]interface{}, len(arr))
+	for i, item := range arr {
+		list[len(arr)-1-i] = item
+	}
+	return list
+}
+
+// Uniq (uniq arr) returns a new array without the duplicated items, the first ones are kept.
+// The numbers that have the same value are duplicated, other values are compared by their json.
+func Uniq(ctx *gisp.Context) interface{} {
+	arr := argList(ctx, 1)
+
+	seen := map[string]bool{}
+	list := []interface{}{}
+	for _, item := range arr {
+		key := uniqKey(item)
+		if !seen[key] {
+			seen[key] = true
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func uniqKey(val interface{}) string {
+	val = gisp.ToNumber(val)
+	if gisp.IsNumber(val) {
+		return "n" + str(val)
+	}
+	if s, ok := val.(string); ok {
+		return "s" + s
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("p%p", val)
+	}
+	return "j" + string(data)
+}
+
+// Top (top arr n by) returns the n largest items in descending order, the items that
+// are equal keep their order. The by is the same as Sort.
+func Top(ctx *gisp.Context) interface{} {
+	arr := argList(ctx, 1)
+	n := int(ctx.ArgNum(2))
+	cmp := comparator(ctx, 3)
+
+	list := sorted(arr, func(a, b interface{}) int { return cmp(b, a) })
+	if n < 0 {
+		n = 0
+	}
+	if n < len(list) {
+		list = list[:n]
+	}
+	return list
+}
@@ -0,0 +1,52 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp/lib"
+)
+
+func TestSort(t *testing.T) {
+	assert.Equal(t, decode(`[null, false, true, 1, 2, 3, "a", "b"]`),
+		runStd(`["sort", ["|", "b", 3, true, 1, null, "a", false, 2]]`))
+
+	assert.Equal(t, decode(`[{"a": 1, "i": 1}, {"a": 1, "i": 2}, {"a": 2, "i": 0}]`),
+		runStd(`["sort", ["$", [{"a": 2, "i": 0}, {"a": 1, "i": 1}, {"a": 1, "i": 2}]], "a"]`))
+
+	assert.Equal(t, decode(`[[1, 1, 1], [2, 2], [3]]`),
+		runStd(`["sort", ["$", [[3], [1, 1, 1], [2, 2]]], "0"]`))
+}
+
+func TestSortComparator(t *testing.T) {
+	assert.Equal(t, decode(`[3, 2, 1]`), runStd(`["sort", ["|", 1, 3, 2], ["fn", ["a", "b"], ["-", ["b"], ["a"]]]]`))
+
+	assert.Panics(t, func() {
+		runStd(`["sort", ["|", 1, 3], ["fn", ["a", "b"], "x"]]`)
+	})
+}
+
+func TestReverse(t *testing.T) {
+	assert.Equal(t, decode(`[3, 2, 1]`), runStd(`["reverse", ["|", 1, 2, 3]]`))
+	assert.Equal(t, decode(`[]`), runStd(`["reverse", ["|"]]`))
+}
+
+func TestUniq(t *testing.T) {
+	assert.Equal(t, decode(`[1, "1", [1], {"a": 1}]`),
+		runStd(`["uniq", ["$", [1, "1", 1.0, [1], [1], {"a": 1}, {"a": 1}]]]`))
+}
+
+func TestTop(t *testing.T) {
+	assert.Equal(t, decode(`[5, 4]`), runStd(`["top", ["|", 1, 5, 3, 4], 2]`))
+	assert.Equal(t, decode(`[{"s": 2, "n": "b"}, {"s": 2, "n": "c"}]`),
+		runStd(`["top", ["$", [{"s": 1, "n": "a"}, {"s": 2, "n": "b"}, {"s": 2, "n": "c"}]], 2, "s"]`))
+	assert.Equal(t, decode(`[1]`), runStd(`["top", ["|", 1], 5]`))
+}
+
+func TestSortLimit(t *testing.T) {
+	old := lib.MaxArrayLen
+	lib.MaxArrayLen = 2
+	defer func() { lib.MaxArrayLen = old }()
+
+	assert.Panics(t, func() { runStd(`["sort", ["|", 1, 2, 3]]`) })
+}
@@ -0,0 +1,210 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ysmood/gisp"
+)
+
+// StdEntry a function of the standard library
+type StdEntry struct {
+	// Name the canonical name used by the nisp js implementation
+	Name string
+
+	Fn func(*gisp.Context) interface{}
+
+	// Pure the result only depends on the arguments and the call has no side effect
+	Pure bool
+
+	// Mutation the call changes the sandbox or the values passed to it
+	Mutation bool
+
+	// MinArgs and MaxArgs the number of the arguments, the MaxArgs is -1 if there's no limit
+	MinArgs, MaxArgs int
+
+	// Doc the usage with the canonical name, such as "(get obj path default) gets ..."
+	Doc string
+}
+
+// StdFilter decides whether an entry is included in the box
+type StdFilter func(StdEntry) bool
+
+// PureOnly only includes the pure functions, the names can be passed to Optimize
+func PureOnly(e StdEntry) bool {
+	return e.Pure
+}
+
+// NoMutation excludes the functions that change the sandbox or the values
+func NoMutation(e StdEntry) bool {
+	return !e.Mutation
+}
+
+// StdLatest the latest version of the standard library
+const StdLatest = "v2"
+
+// the names of a released version never change, new functions go to a new version
+var stdVersions = map[string][]StdEntry{
+	"v1": stdV1,
+	"v2": extend(stdV1,
+		// they call the callbacks that can be any function in the sandbox, so they are not pure,
+		// so do sort, top and map-values that take the callbacks
+		StdEntry{"map", Map, false, false, 2, 2, "(map coll fn) returns the results of (fn item key) for each item, dicts are mapped to dicts, a fn given by name gets (fn item)"},
+		StdEntry{"filter", Filter, false, false, 2, 2, "(filter coll fn) keeps the items that (fn item key) returns true, a fn given by name gets (fn item)"},
+		StdEntry{"reduce", Reduce, false, false, 2, 3, "(reduce coll fn init) calls (fn acc item key) for each item, returns the last acc, a fn given by name gets (fn acc item)"},
+		StdEntry{"find", Find, false, false, 2, 2, "(find coll fn) returns the first item that (fn item key) returns true, nil if not found, a fn given by name gets (fn item)"},
+		StdEntry{"find-index", FindIndex, false, false, 2, 2, "(find-index coll fn) returns the index or key of the first item that (fn item key) returns true, a fn given by name gets (fn item)"},
+		StdEntry{"some", Some, false, false, 2, 2, "(some coll fn) checks if (fn item key) returns true for any item, a fn given by name gets (fn item)"},
+		StdEntry{"every", Every, false, false, 2, 2, "(every coll fn) checks if (fn item key) returns true for all items, a fn given by name gets (fn item)"},
+		StdEntry{"flat-map", FlatMap, false, false, 2, 2, "(flat-map coll fn) maps the items by (fn item key) and flattens the array results by one level, a fn given by name gets (fn item)"},
+		StdEntry{"group-by", GroupBy, false, false, 2, 2, "(group-by coll fn) groups the items by the string of (fn item key), a fn given by name gets (fn item)"},
+		StdEntry{"count-by", CountBy, false, false, 2, 2, "(count-by coll fn) counts the items by the string of (fn item key), a fn given by name gets (fn item)"},
+		StdEntry{"sort", Sort, false, false, 1, 2, "(sort arr by) returns a stably sorted copy, the by is a key path such as \"user.age\" or (fn (a b) number), nil for the natural order"},
+		StdEntry{"reverse", Reverse, true, false, 1, 1, "(reverse arr) returns a reversed copy"},
+		StdEntry{"uniq", Uniq, true, false, 1, 1, "(uniq arr) removes the duplicated items, the first ones are kept"},
+		StdEntry{"top", Top, false, false, 2, 3, "(top arr n by) returns the n largest items in descending order, the by is the same as sort"},
+		StdEntry{"keys", Keys, true, false, 1, 1, "(keys dict) returns the keys in order, the keys of a map are sorted"},
+		StdEntry{"values", Values, true, false, 1, 1, "(values dict) returns the values in the order of the keys"},
+		StdEntry{"entries", Entries, true, false, 1, 1, "(entries dict) returns the [key value] pairs in the order of the keys"},
+		StdEntry{"from-entries", FromEntries, true, false, 1, 1, "(from-entries arr) creates a dict from the [key value] pairs"},
+		StdEntry{"merge", Merge, true, false, 0, -1, "(merge a b ...) creates a dict that has the entries of all the dicts, the later ones win"},
+		StdEntry{"deep-merge", DeepMerge, true, false, 0, -1, "(deep-merge a b ...) like merge, but the values that are both dicts are merged recursively"},
+		StdEntry{"pick", Pick, true, false, 2, 2, "(pick dict keys) creates a dict that only has the keys"},
+		StdEntry{"omit", Omit, true, false, 2, 2, "(omit dict keys) creates a dict without the keys"},
+		StdEntry{"has-key", HasKey, true, false, 2, 2, "(has-key dict key) checks if the dict has the key"},
+		StdEntry{"map-values", MapValues, false, false, 2, 2, "(map-values dict fn) creates a dict of the results of (fn value key), a fn given by name gets (fn value)"},
+		StdEntry{"upper", Upper, true, false, 1, 1, "(upper str) converts the string to upper case"},
+		StdEntry{"lower", Lower, true, false, 1, 1, "(lower str) converts the string to lower case"},
+		StdEntry{"trim", Trim, true, false, 1, 2, "(trim str cutset) removes the leading and trailing chars in the cutset, white spaces by default"},
+		StdEntry{"trim-start", TrimStart, true, false, 1, 2, "(trim-start str cutset) removes the leading chars in the cutset, white spaces by default"},
+		StdEntry{"trim-end", TrimEnd, true, false, 1, 2, "(trim-end str cutset) removes the trailing chars in the cutset, white spaces by default"},
+		StdEntry{"replace", Replace, true, false, 3, 3, "(replace str old new) replaces the first old with the new"},
+		StdEntry{"replace-all", ReplaceAll, true, false, 3, 3, "(replace-all str old new) replaces all the old with the new"},
+		StdEntry{"starts-with", StartsWith, true, false, 2, 2, "(starts-with str prefix) checks if the string starts with the prefix"},
+		StdEntry{"ends-with", EndsWith, true, false, 2, 2, "(ends-with str suffix) checks if the string ends with the suffix"},
+		StdEntry{"pad-start", PadStart, true, false, 2, 3, "(pad-start str len pad) pads the start to the length with the pad, a space by default"},
+		StdEntry{"pad-end", PadEnd, true, false, 2, 3, "(pad-end str len pad) pads the end to the length with the pad, a space by default"},
+		StdEntry{"repeat", Repeat, true, false, 2, 2, "(repeat str n) repeats the string n times"},
+		StdEntry{"join", Join, true, false, 1, 2, "(join arr sep) joins the items with the separator"},
+		StdEntry{"char-at", CharAt, true, false, 2, 2, "(char-at str index) returns the char at the index, negative index counts from the end, empty string if out of range"},
+		StdEntry{"re-test", ReTest, true, false, 2, 2, "(re-test str pattern) checks if the string matches the RE2 pattern"},
+		StdEntry{"re-match", ReMatch, true, false, 2, 2, "(re-match str pattern) returns the first match and its groups, nil if not matched"},
+		StdEntry{"re-find-all", ReFindAll, true, false, 2, 3, "(re-find-all str pattern n) returns all the matches, the optional n limits the number"},
+		StdEntry{"re-replace", ReReplace, true, false, 3, 3, "(re-replace str pattern repl) replaces all the matches, $1 or ${name} in the repl is the group"},
+		StdEntry{"re-split", ReSplit, true, false, 2, 3, "(re-split str pattern n) splits the string by the pattern, the optional n limits the number"},
+		StdEntry{"fmt", Fmt, false, false, 1, 2, "(fmt template data) replaces the {{path | filter arg ...}} placeholders with the values in the data, or in the current scope without data"},
+		StdEntry{"fmt-html", FmtHTML, false, false, 1, 2, "(fmt-html template data) like fmt, but escapes the html of the values unless the raw filter is used"},
+	),
+}
+
+var stdV1 = []StdEntry{
+	{"$", Raw, true, false, 1, 1, "($ value) returns the value without evaluating it"},
+	{"throw", Throw, false, false, 1, 1, "(throw message) stops the run with the error message"},
+	{"get", Get, true, false, 2, 3, "(get obj path default) gets the value of the path, such as \"a.b.0\", returns the default if not found"},
+	{"set", Set, false, true, 3, 3, "(set obj path value) sets the value of the path, the missing dicts and arrays on the path will be created"},
+	{"del", Del, false, true, 2, 2, "(del obj path) deletes the value of the path"},
+	{"str", Str, true, false, 1, 1, "(str value) converts the value to string"},
+	{"includes", Includes, true, false, 2, 2, "(includes arr value) checks if the array has the value"},
+	{"|", Arr, true, false, 0, -1, "(| a b ...) creates an array"},
+	{":", Dict, true, false, 0, -1, "(: key value ...) creates a dict that keeps the order of the keys"},
+	{"do", Do, true, false, 0, -1, "(do exp ...) runs each expression, returns the last value"},
+	{"def", Def, false, true, 2, 2, "(def name value) defines the name in the current scope"},
+	{"redef", Redef, false, true, 2, 2, "(redef name value) updates the nearest defined name, or defines it in the current scope"},
+	{"if", If, true, false, 2, 3, "(if cond then else) runs then if cond is true, or else"},
+	{"+", Add, true, false, 0, -1, "(+ a b ...) adds numbers, or concatenates strings if any of them is string"},
+	{"-", Minus, true, false, 1, -1, "(- a b ...) subtracts the rest numbers from a"},
+	{"*", Multiply, true, false, 1, -1, "(* a b ...) multiplies numbers"},
+	{"**", Power, true, false, 2, 2, "(** a b) returns a to the power of b"},
+	{"/", Divide, true, false, 1, -1, "(/ a b ...) divides a by the rest numbers"},
+	{"%", Mod, true, false, 2, 2, "(% a b) returns the remainder of a / b"},
+	{"==", Eq, true, false, 2, -1, "(== a b ...) checks if all the values equal a"},
+	{"!=", Ne, true, false, 2, 2, "(!= a b) checks if a doesn't equal b"},
+	{"<", Lt, true, false, 2, -1, "(< a b ...) checks if the values are increasing"},
+	{"<=", Le, true, false, 2, -1, "(<= a b ...) checks if the values are not decreasing"},
+	{">", Gt, true, false, 2, -1, "(> a b ...) checks if the values are decreasing"},
+	{">=", Ge, true, false, 2, -1, "(>= a b ...) checks if the values are not increasing"},
+	{"!", Not, true, false, 1, 1, "(! bool) negates the bool"},
+	{"&&", And, true, false, 0, -1, "(&& a b ...) returns true if all are true, stops at the first false"},
+	{"||", Or, true, false, 0, -1, "(|| a b ...) returns true if any is true, stops at the first true"},
+	{"switch", Switch, true, false, 0, -1, "(switch exp (case value then) ... (default else)) runs the first case that equals exp, without exp runs the first case that is true"},
+	// they bind names in a new closure, so they are not plain calls
+	{"fn", Fn, false, false, 2, 2, "(fn (arg ...) body) creates a closure"},
+	{"for", For, false, false, 4, 4, "(for key value collection body) runs body for each item of the array or dict"},
+	{"len", Len, true, false, 1, 1, "(len value) returns the length of the array, dict or string, strings are counted in unicode code points, -1 for other types"},
+	{"concat", Concat, true, false, 0, -1, "(concat a b ...) concatenates the arrays and values into a new array"},
+	{"append", Append, true, false, 2, 2, "(append arr value) returns a new array that has the value appended"},
+	{"split", Split, true, false, 2, 2, "(split str sep) splits the string by the separator"},
+	{"slice", Slice, true, false, 2, 3, "(slice value start end) slices the string or array, the end is optional, negative indexes count from the end"},
+	{"indexOf", IndexOf, true, false, 2, 3, "(indexOf value target from) returns the index of the target in the string or array from the optional from, -1 if not found"},
+}
+
+// extend returns a new list of the entries that has the base and the extra
+func extend(base []StdEntry, extra ...StdEntry) []StdEntry {
+	return append(append([]StdEntry{}, base...), extra...)
+}
+
+// StdVersions returns the available versions of the standard library
+func StdVersions() []string {
+	list := []string{}
+	for v := range stdVersions {
+		list = append(list, v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// StdEntries returns the entries of the version, only the ones pass all the filters are returned
+func StdEntries(version string, filters ...StdFilter) ([]StdEntry, error) {
+	entries, has := stdVersions[version]
+	if !has {
+		return nil, fmt.Errorf("unknown std version %q", version)
+	}
+
+	list := []StdEntry{}
+	for _, e := range entries {
+		if pass(e, filters) {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+func pass(e StdEntry, filters []StdFilter) bool {
+	for _, f := range filters {
+		if !f(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// StdOf returns a new box of the version with the canonical names
+func StdOf(version string, filters ...StdFilter) (gisp.Box, error) {
+	entries, err := StdEntries(version, filters...)
+	if err != nil {
+		return nil, err
+	}
+
+	box := gisp.Box{}
+	for _, e := range entries {
+		box[e.Name] = e.Fn
+	}
+	return box, nil
+}
+
+// Std returns a new box of the latest version with the canonical names, such as
+// "+" for Add, "$" for Raw and "get" for Get
+func Std(filters ...StdFilter) gisp.Box {
+	box, _ := StdOf(StdLatest, filters...)
+	return box
+}
+
+// StdNames returns the sorted names of the latest version
+func StdNames(filters ...StdFilter) []string {
+	names := []string{}
+	for name := range Std(filters...) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,57 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+func TestStd(t *testing.T) {
+	out := gisp.Run(&gisp.Context{
+		AST:     decode(`["do", ["def", "a", ["|", 1, 2]], ["+", ["len", ["a"]], ["get", ["a"], "1"]]]`),
+		Sandbox: gisp.New(lib.Std()),
+	})
+	assert.Equal(t, float64(4), out)
+
+	box, err := lib.StdOf("v1")
+	assert.Nil(t, err)
+	assert.Len(t, box, 37)
+
+	_, err = lib.StdOf("v0")
+	assert.EqualError(t, err, `unknown std version "v0"`)
+
+	assert.Contains(t, lib.StdVersions(), lib.StdLatest)
+}
+
+func TestStdFilters(t *testing.T) {
+	pure := lib.StdNames(lib.PureOnly)
+	assert.Contains(t, pure, "+")
+	assert.NotContains(t, pure, "def")
+	assert.NotContains(t, pure, "throw")
+	assert.NotContains(t, pure, "for")
+	assert.NotContains(t, pure, "fn")
+
+	noMutation := lib.Std(lib.NoMutation)
+	assert.Contains(t, noMutation, "throw")
+	assert.NotContains(t, noMutation, "set")
+
+	out := lib.Optimize(decode(`["+", 1, ["*", 2, 3]]`), gisp.New(lib.Std()), pure...)
+	assert.Equal(t, float64(7), out)
+}
+
+func TestStdDocumented(t *testing.T) {
+	for _, fn := range lib.Std() {
+		_, ok := lib.Doc(fn)
+		assert.True(t, ok)
+	}
+}
+
+func TestStdArity(t *testing.T) {
+	entries, _ := lib.StdEntries(lib.StdLatest)
+	for _, e := range entries {
+		assert.True(t, e.MinArgs >= 0, e.Name)
+		assert.True(t, e.MaxArgs == -1 || e.MaxArgs >= e.MinArgs, e.Name)
+	}
+}
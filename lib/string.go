@@ -0,0 +1,184 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ysmood/gisp"
+)
+
+// The lengths and indexes of the string functions are counted in unicode code points,
+// the same as Len, Slice and IndexOf.
+// The results are limited by MaxStringLen, the check happens before the string is built.
+
+// checkLen reports error if the length of the string to build exceeds MaxStringLen
+func checkLen(ctx *gisp.Context, n int) {
+	if n > MaxStringLen || n < 0 {
+		ctx.Error(fmt.Sprintf("max string length exceeded %v", MaxStringLen))
+	}
+}
+
+// Upper (upper str) converts the string to upper case
+func Upper(ctx *gisp.Context) interface{} {
+	s := ctx.ArgStr(1)
+	checkLen(ctx, caseLen(s, unicode.ToUpper))
+	return strings.ToUpper(s)
+}
+
+// Lower (lower str) converts the string to lower case
+func Lower(ctx *gisp.Context) interface{} {
+	s := ctx.ArgStr(1)
+	checkLen(ctx, caseLen(s, unicode.ToLower))
+	return strings.ToLower(s)
+}
+
+// caseLen returns the length of the string after the case mapping, it can be longer than the string
+func caseLen(s string, mapping func(rune) rune) int {
+	n := 0
+	for _, r := range s {
+		n += utf8.RuneLen(mapping(r))
+	}
+	return n
+}
+
+// Trim (trim str cutset) removes the leading and trailing chars in the cutset, the white spaces by default
+func Trim(ctx *gisp.Context) interface{} {
+	if ctx.Len() > 2 {
+		return strings.Trim(ctx.ArgStr(1), ctx.ArgStr(2))
+	}
+	return strings.TrimSpace(ctx.ArgStr(1))
+}
+
+// TrimStart (trim-start str cutset) removes the leading chars in the cutset, the white spaces by default
+func TrimStart(ctx *gisp.Context) interface{} {
+	if ctx.Len() > 2 {
+		return strings.TrimLeft(ctx.ArgStr(1), ctx.ArgStr(2))
+	}
+	return strings.TrimLeftFunc(ctx.ArgStr(1), unicode.IsSpace)
+}
+
+// TrimEnd (trim-end str cutset) removes the trailing chars in the cutset, the white spaces by default
+func TrimEnd(ctx *gisp.Context) interface{} {
+	if ctx.Len() > 2 {
+		return strings.TrimRight(ctx.ArgStr(1), ctx.ArgStr(2))
+	}
+	return strings.TrimRightFunc(ctx.ArgStr(1), unicode.IsSpace)
+}
+
+// Replace (replace str old new) replaces the first old with the new
+func Replace(ctx *gisp.Context) interface{} {
+	s, old, new := ctx.ArgStr(1), ctx.ArgStr(2), ctx.ArgStr(3)
+	checkLen(ctx, len(s)+len(new)-len(old))
+	return strings.Replace(s, old, new, 1)
+}
+
+// ReplaceAll (replace-all str old new) replaces all the old with the new
+func ReplaceAll(ctx *gisp.Context) interface{} {
+	s, old, new := ctx.ArgStr(1), ctx.ArgStr(2), ctx.ArgStr(3)
+	n := strings.Count(s, old)
+	checkLen(ctx, len(s)+n*(len(new)-len(old)))
+	return strings.Replace(s, old, new, -1)
+}
+
+// StartsWith (starts-with str prefix) checks if the string starts with the prefix
+func StartsWith(ctx *gisp.Context) interface{} {
+	return strings.HasPrefix(ctx.ArgStr(1), ctx.ArgStr(2))
+}
+
+// EndsWith (ends-with str suffix) checks if the string ends with the suffix
+func EndsWith(ctx *gisp.Context) interface{} {
+	return strings.HasSuffix(ctx.ArgStr(1), ctx.ArgStr(2))
+}
+
+// padding returns the padding that makes the string have the length
+func padding(ctx *gisp.Context) (string, string) {
+	s := ctx.ArgStr(1)
+	n := int(ctx.ArgNum(2))
+	pad := " "
+	if ctx.Len() > 3 {
+		pad = ctx.ArgStr(3)
+	}
+
+	count := n - utf8.RuneCountInString(s)
+	if count <= 0 || pad == "" {
+		return s, ""
+	}
+
+	// the result has at least count bytes
+	checkLen(ctx, len(s)+count)
+
+	padRunes := []rune(pad)
+	full := strings.Repeat(pad, count/len(padRunes))
+	rest := string(padRunes[:count%len(padRunes)])
+	checkLen(ctx, len(s)+len(full)+len(rest))
+
+	return s, full + rest
+}
+
+// PadStart (pad-start str len pad) pads the start of the string with the pad to the length,
+// the pad is a space by default
+func PadStart(ctx *gisp.Context) interface{} {
+	s, pad := padding(ctx)
+	return pad + s
+}
+
+// PadEnd (pad-end str len pad) pads the end of the string with the pad to the length,
+// the pad is a space by default
+func PadEnd(ctx *gisp.Context) interface{} {
+	s, pad := padding(ctx)
+	return s + pad
+}
+
+// Repeat (repeat str n) repeats the string n times
+func Repeat(ctx *gisp.Context) interface{} {
+	s := ctx.ArgStr(1)
+	n := int(ctx.ArgNum(2))
+	if n <= 0 {
+		return ""
+	}
+	if len(s) > 0 && n > MaxStringLen/len(s) {
+		checkLen(ctx, -1)
+	}
+	return strings.Repeat(s, n)
+}
+
+// Join (join arr sep) joins the items with the separator, the items are converted the same way as Fmt,
+// nil is empty, arrays and dicts are json
+func Join(ctx *gisp.Context) interface{} {
+	arr := ctx.ArgArr(1)
+	sep := ""
+	if ctx.Len() > 2 {
+		sep = ctx.ArgStr(2)
+	}
+
+	var b strings.Builder
+	for i, item := range arr {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(text(item))
+		checkLen(ctx, b.Len())
+	}
+	return b.String()
+}
+
+// CharAt (char-at str index) returns the char at the index, empty string if out of range.
+// The negative index counts from the end.
+func CharAt(ctx *gisp.Context) interface{} {
+	s := ctx.ArgStr(1)
+	index := ctx.ArgNum(2)
+	if index < 0 {
+		index += float64(utf8.RuneCountInString(s))
+	}
+
+	i := 0
+	for _, r := range s {
+		if float64(i) == index {
+			return string(r)
+		}
+		i++
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp/lib"
+)
+
+func TestStringCase(t *testing.T) {
+	assert.Equal(t, "ABÇ", runStd(`["upper", "abç"]`))
+	assert.Equal(t, "abç", runStd(`["lower", "ABÇ"]`))
+}
+
+func TestTrim(t *testing.T) {
+	assert.Equal(t, "a b", runStd(`["trim", " \t a b\n"]`))
+	assert.Equal(t, "a b\n", runStd(`["trim-start", " \t a b\n"]`))
+	assert.Equal(t, " \t a b", runStd(`["trim-end", " \t a b\n"]`))
+	assert.Equal(t, "a", runStd(`["trim", "xyaxy", "xy"]`))
+	assert.Equal(t, "axy", runStd(`["trim-start", "xyaxy", "xy"]`))
+	assert.Equal(t, "xya", runStd(`["trim-end", "xyaxy", "xy"]`))
+}
+
+func TestReplace(t *testing.T) {
+	assert.Equal(t, "b-a-a", runStd(`["replace", "a-a-a", "a", "b"]`))
+	assert.Equal(t, "b-b-b", runStd(`["replace-all", "a-a-a", "a", "b"]`))
+}
+
+func TestStartsEndsWith(t *testing.T) {
+	assert.Equal(t, true, runStd(`["starts-with", "/api/a", "/api"]`))
+	assert.Equal(t, false, runStd(`["ends-with", "/api/a", "/api"]`))
+	assert.Equal(t, true, runStd(`["ends-with", "a.json", ".json"]`))
+}
+
+func TestPad(t *testing.T) {
+	assert.Equal(t, "007", runStd(`["pad-start", "7", 3, "0"]`))
+	assert.Equal(t, "é  ", runStd(`["pad-end", "é", 3]`))
+	assert.Equal(t, "abaé", runStd(`["pad-start", "é", 4, "ab"]`))
+	assert.Equal(t, "long", runStd(`["pad-start", "long", 2]`))
+}
+
+func TestRepeatJoin(t *testing.T) {
+	assert.Equal(t, "ababab", runStd(`["repeat", "ab", 3]`))
+	assert.Equal(t, "", runStd(`["repeat", "ab", -1]`))
+	assert.Equal(t, "a,1,true", runStd(`["join", ["|", "a", 1, true], ","]`))
+	assert.Equal(t, "ab", runStd(`["join", ["|", "a", "b"]]`))
+
+	// the same as fmt
+	assert.Equal(t, "a,,[1]", runStd(`["join", ["|", "a", null, ["$", [1]]], ","]`))
+	assert.Equal(t, runStd(`["fmt", "a,{{0}},{{1}}", ["|", null, ["$", [1]]]]`), runStd(`["join", ["|", "a", null, ["$", [1]]], ","]`))
+}
+
+func TestCharAt(t *testing.T) {
+	assert.Equal(t, "é", runStd(`["char-at", "héllo", 1]`))
+	assert.Equal(t, "", runStd(`["char-at", "héllo", 9]`))
+}
+
+func TestStringLimit(t *testing.T) {
+	old := lib.MaxStringLen
+	lib.MaxStringLen = 5
+	defer func() { lib.MaxStringLen = old }()
+
+	assert.Panics(t, func() { runStd(`["repeat", "ab", 3]`) })
+	assert.Panics(t, func() { runStd(`["upper", "ɐɐ"]`) })
+	assert.Panics(t, func() { runStd(`["lower", "ȺȺ"]`) })
+	assert.Equal(t, "ɐɐ", runStd(`["lower", "ɐɐ"]`))
+	assert.Panics(t, func() { runStd(`["pad-start", "a", 1e18]`) })
+	assert.Panics(t, func() { runStd(`["replace-all", "aaa", "a", "bb"]`) })
+	assert.Panics(t, func() { runStd(`["join", ["|", "abc", "abc"]]`) })
+	assert.Equal(t, "aa", runStd(`["repeat", "a", 2]`))
+}
@@ -2,8 +2,11 @@ package lib
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/ysmood/gisp"
 )
 
 func f2s(v interface{}) string {
@@ -26,6 +29,10 @@ func str(val interface{}) (str string) {
 		str = val.(string)
 	case float64:
 		str = f2s(val)
+	case int64:
+		str = strconv.FormatInt(val.(int64), 10)
+	case gisp.Decimal:
+		str = val.(gisp.Decimal).String()
 	case []byte:
 		str = string(val.([]byte))
 	default:
@@ -43,6 +50,15 @@ func clone(obj interface{}) interface{} {
 		}
 		return new
 
+	case *gisp.Dict:
+		dict := obj.(*gisp.Dict)
+		new := gisp.NewDict()
+		for _, k := range dict.Keys() {
+			v, _ := dict.Get(k)
+			new.Set(k, clone(v))
+		}
+		return new
+
 	case []interface{}:
 		new := make([]interface{}, len(obj.([]interface{})))
 		for k, v := range obj.([]interface{}) {
@@ -74,8 +90,129 @@ func toJSONPath(pathRaw interface{}) (paths []interface{}) {
 		paths = []interface{}{
 			uint64(pathRaw.(float64)),
 		}
+	case int64:
+		paths = []interface{}{
+			uint64(pathRaw.(int64)),
+		}
 	default:
 		paths = []interface{}{}
 	}
 	return
 }
+
+// getPath gets the value of the paths, see toJSONPath
+func getPath(obj interface{}, paths []interface{}) (interface{}, bool) {
+	l := len(paths)
+
+	if l == 0 {
+		return nil, false
+	}
+
+	for i := 0; i < l; i++ {
+		p := paths[i]
+		switch p.(type) {
+		case string:
+			var has bool
+			obj, has = getKey(obj, p.(string))
+
+			if !has {
+				return nil, false
+			}
+		case uint64:
+			switch obj.(type) {
+			case []interface{}:
+				arr := obj.([]interface{})
+				if int(p.(uint64)) >= len(arr) {
+					return nil, false
+				}
+				obj = arr[p.(uint64)]
+			case map[string]interface{}, *gisp.Dict:
+				var has bool
+				index := strconv.FormatUint(p.(uint64), 10)
+				obj, has = getKey(obj, index)
+
+				if !has {
+					return nil, false
+				}
+			default:
+				var has bool
+				obj, has = reflectGet(obj, strconv.FormatUint(p.(uint64), 10))
+
+				if !has {
+					return nil, false
+				}
+			}
+		default:
+			return nil, false
+		}
+	}
+	return obj, true
+}
+
+func isDict(obj interface{}) bool {
+	switch obj.(type) {
+	case map[string]interface{}, *gisp.Dict:
+		return true
+	default:
+		return false
+	}
+}
+
+func getKey(obj interface{}, key string) (val interface{}, has bool) {
+	switch obj.(type) {
+	case map[string]interface{}:
+		val, has = obj.(map[string]interface{})[key]
+	case *gisp.Dict:
+		val, has = obj.(*gisp.Dict).Get(key)
+	default:
+		val, has = reflectGet(obj, key)
+	}
+	return
+}
+
+func setKey(obj interface{}, key string, val interface{}) {
+	switch obj.(type) {
+	case *gisp.Dict:
+		obj.(*gisp.Dict).Set(key, val)
+	default:
+		obj.(map[string]interface{})[key] = val
+	}
+}
+
+func delKey(obj interface{}, key string) {
+	switch obj.(type) {
+	case *gisp.Dict:
+		obj.(*gisp.Dict).Del(key)
+	default:
+		delete(obj.(map[string]interface{}), key)
+	}
+}
+
+// noValues stops the run if the special form is called by gisp.Call,
+// it reads its arguments as code, the values must not run as code
+func noValues(ctx *gisp.Context, name string) {
+	if ctx.Args != nil {
+		ctx.Error(name + " can't be called with values")
+	}
+}
+
+// newContainer creates the container for the next path
+func newContainer(next interface{}, ordered bool) interface{} {
+	if isUint64(next) {
+		return make([]interface{}, int(next.(uint64)+1))
+	}
+	if ordered {
+		return gisp.NewDict()
+	}
+	return map[string]interface{}{}
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys(dict map[string]interface{}) []string {
+	keys := make([]string, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
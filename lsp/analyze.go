@@ -0,0 +1,327 @@
+package lsp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+)
+
+type arity struct {
+	min, max int
+}
+
+// the number of the arguments of the lib functions, -1 means no limit, it's from the std entries
+var arities = map[uintptr]arity{}
+
+func init() {
+	for _, v := range lib.StdVersions() {
+		entries, _ := lib.StdEntries(v)
+		for _, e := range entries {
+			arities[pointer(e.Fn)] = arity{e.MinArgs, e.MaxArgs}
+		}
+	}
+}
+
+func pointer(val interface{}) uintptr {
+	if fn, ok := val.(func(*gisp.Context) interface{}); ok {
+		return reflect.ValueOf(fn).Pointer()
+	}
+	return 0
+}
+
+func isFn(val interface{}, fn func(*gisp.Context) interface{}) bool {
+	p := pointer(val)
+	return p != 0 && p == reflect.ValueOf(fn).Pointer()
+}
+
+// scope the names bound by the script, fn and for create new scopes
+type scope struct {
+	parent *scope
+	names  map[string]*node
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, names: map[string]*node{}}
+}
+
+func (s *scope) lookup(name string) *node {
+	for ; s != nil; s = s.parent {
+		if n, has := s.names[name]; has {
+			return n
+		}
+	}
+	return nil
+}
+
+func (s *scope) bind(name string, n *node) {
+	if _, has := s.names[name]; !has {
+		s.names[name] = n
+	}
+}
+
+// call an array whose head is a name
+type call struct {
+	node  *node
+	scope *scope
+	name  string
+
+	// the node that binds the name, nil if it's from the sandbox
+	def *node
+}
+
+type diagnostic struct {
+	start, end int
+	message    string
+}
+
+// analysis the result of checking a script against a sandbox
+type analysis struct {
+	sandbox *gisp.Sandbox
+	root    *node
+
+	diagnostics []diagnostic
+
+	// the calls indexed by their head nodes
+	calls map[*node]*call
+
+	// the scopes of the arrays that are evaluated
+	scopes map[*node]*scope
+}
+
+func analyze(text string, sandbox *gisp.Sandbox) *analysis {
+	a := &analysis{
+		sandbox: sandbox,
+		calls:   map[*node]*call{},
+		scopes:  map[*node]*scope{},
+	}
+
+	root, err := parse(text)
+	if err != nil {
+		e := err.(*parseError)
+		a.report(&node{start: e.offset, end: e.offset}, e.message)
+		return a
+	}
+	a.root = root
+
+	a.visit(root, newScope(nil))
+
+	// resolve after all the bindings are collected, a fn body can use a name defined after it
+	for _, head := range a.heads() {
+		c := a.calls[head]
+		if c.def = c.scope.lookup(c.name); c.def != nil {
+			continue
+		}
+		if _, has := sandbox.Get(c.name); !has {
+			a.report(head, fmt.Sprintf("%q is undefined", c.name))
+		}
+	}
+
+	sort.SliceStable(a.diagnostics, func(i, j int) bool {
+		return a.diagnostics[i].start < a.diagnostics[j].start
+	})
+
+	return a
+}
+
+// heads returns the head nodes of the calls in source order
+func (a *analysis) heads() []*node {
+	list := []*node{}
+	var walk func(n *node)
+	walk = func(n *node) {
+		if _, has := a.calls[n]; has {
+			list = append(list, n)
+		}
+		for _, child := range n.items {
+			walk(child)
+		}
+	}
+	walk(a.root)
+	return list
+}
+
+func (a *analysis) report(n *node, msg string) {
+	a.diagnostics = append(a.diagnostics, diagnostic{n.start, n.end, msg})
+}
+
+// visit follows the rules of gisp.Run and the lib special forms to find out
+// which arrays are calls and where the names are bound
+func (a *analysis) visit(n *node, s *scope) {
+	if n.kind != kindArray {
+		return
+	}
+	a.scopes[n] = s
+
+	if len(n.items) == 0 {
+		return
+	}
+
+	head := n.items[0]
+	args := n.items[1:]
+
+	switch head.kind {
+	case kindArray:
+		a.visit(head, s)
+		a.visitAll(args, s)
+		return
+	case kindString:
+	default:
+		a.report(head, "the function name must be a string")
+		return
+	}
+
+	name := head.value.(string)
+	a.calls[head] = &call{node: n, scope: s, name: name}
+
+	var fn interface{}
+	if s.lookup(name) == nil {
+		fn, _ = a.sandbox.Get(name)
+	}
+
+	if ar, has := arities[pointer(fn)]; has {
+		if len(args) < ar.min || ar.max >= 0 && len(args) > ar.max {
+			a.report(n, fmt.Sprintf("%q expects %s, got %d", name, ar, len(args)))
+		}
+	}
+
+	switch {
+	case isFn(fn, lib.Raw):
+	case isFn(fn, lib.Def), isFn(fn, lib.Redef):
+		if len(args) > 0 {
+			a.bindName(args[0], s, s)
+		}
+		a.visitAll(args[min(1, len(args)):], s)
+	case isFn(fn, lib.Fn):
+		body := newScope(s)
+		if len(args) > 0 {
+			if args[0].kind == kindArray {
+				for _, param := range args[0].items {
+					a.bindName(param, body, s)
+				}
+			} else {
+				a.report(args[0], "the parameters of fn must be an array of names")
+			}
+		}
+		if len(args) > 1 {
+			a.visitAll(args[1:], body)
+		}
+	case isFn(fn, lib.For):
+		body := newScope(s)
+		for i := 0; i < 2 && i < len(args); i++ {
+			a.bindName(args[i], body, s)
+		}
+		if len(args) > 2 {
+			a.visit(args[2], s)
+		}
+		if len(args) > 3 {
+			a.visitAll(args[3:], body)
+		}
+	case isFn(fn, lib.Switch):
+		a.visitSwitch(args, s)
+	default:
+		a.visitAll(args, s)
+	}
+}
+
+func (a *analysis) visitAll(list []*node, s *scope) {
+	for _, n := range list {
+		a.visit(n, s)
+	}
+}
+
+// bindName binds the name node to the scope, the computed name is visited in the outer scope
+func (a *analysis) bindName(n *node, s, outer *scope) {
+	switch n.kind {
+	case kindString:
+		s.bind(n.value.(string), n)
+	case kindArray:
+		a.visit(n, outer)
+	default:
+		a.report(n, "the name must be a string")
+	}
+}
+
+// visitSwitch follows the same rules as lib.Switch to find out the expression,
+// the cases and the default
+func (a *analysis) visitSwitch(args []*node, s *scope) {
+	if len(args) == 0 {
+		return
+	}
+
+	first := args[0]
+	if first.kind != kindArray || len(first.items) == 1 && !isName(first.items[0], "case") {
+		a.visit(first, s)
+		args = args[1:]
+	}
+
+	for i, n := range args {
+		switch {
+		case i == len(args)-1 && len(n.items) == 2 && isName(n.items[0], "default"):
+			a.visit(n.items[1], s)
+		case len(n.items) == 3 && isName(n.items[0], "case"):
+			a.visitAll(n.items[1:], s)
+		default:
+			a.report(n, `switch expects ["case", value, body] or ["default", body] as the last one`)
+		}
+	}
+}
+
+func isName(n *node, name string) bool {
+	return n.kind == kindString && n.value.(string) == name
+}
+
+func (ar arity) String() string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 argument"
+		}
+		return fmt.Sprintf("%d arguments", n)
+	}
+
+	switch {
+	case ar.max < 0:
+		return "at least " + plural(ar.min)
+	case ar.min == ar.max:
+		return plural(ar.min)
+	default:
+		return fmt.Sprintf("%d to %d arguments", ar.min, ar.max)
+	}
+}
+
+// names returns the names visible to the array node
+func (a *analysis) names(arr *node) map[string]*node {
+	names := map[string]*node{}
+	for _, name := range a.sandbox.Names() {
+		names[name] = nil
+	}
+	for s := a.scopes[arr]; s != nil; s = s.parent {
+		for name, n := range s.names {
+			if _, has := names[name]; !has || names[name] == nil {
+				names[name] = n
+			}
+		}
+	}
+	return names
+}
+
+// doc returns the documentation of the name in the sandbox
+func doc(sandbox *gisp.Sandbox, docs map[string]string, name string) string {
+	if d, has := docs[name]; has {
+		return d
+	}
+
+	val, has := sandbox.Get(name)
+	if !has {
+		return ""
+	}
+	if d, ok := lib.Doc(val); ok {
+		return d
+	}
+	if _, ok := val.(func(*gisp.Context) interface{}); ok {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", val))
+}
@@ -0,0 +1,293 @@
+package lsp
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type kind int
+
+const (
+	kindValue kind = iota
+	kindString
+	kindArray
+	kindObject
+)
+
+// node a json value with its byte range in the source
+type node struct {
+	kind  kind
+	value interface{}
+	start int
+	end   int
+
+	// the items of an array or the values of an object
+	items []*node
+
+	// the keys of an object
+	keys []*node
+}
+
+// find returns the deepest node that contains the offset
+func (n *node) find(offset int) *node {
+	if n == nil || offset < n.start || offset > n.end {
+		return nil
+	}
+	for _, child := range append(n.keys, n.items...) {
+		if found := child.find(offset); found != nil {
+			return found
+		}
+	}
+	return n
+}
+
+type parseError struct {
+	message string
+	offset  int
+}
+
+func (e *parseError) Error() string {
+	return e.message
+}
+
+// parse parses the json and keeps the position of each value
+func parse(text string) (*node, error) {
+	p := &parser{text: text}
+
+	p.skip()
+	n, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skip()
+	if p.pos < len(p.text) {
+		return nil, p.error("invalid data after the top-level value")
+	}
+	return n, nil
+}
+
+type parser struct {
+	text string
+	pos  int
+}
+
+func (p *parser) error(msg string) error {
+	return &parseError{msg, p.pos}
+}
+
+func (p *parser) skip() {
+	for p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) value() (*node, error) {
+	if p.pos >= len(p.text) {
+		return nil, p.error("unexpected end of input")
+	}
+
+	switch c := p.text[p.pos]; {
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.object()
+	case c == '"':
+		return p.string()
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	}
+
+	for i, lit := range []string{"true", "false", "null"} {
+		if len(p.text)-p.pos >= len(lit) && p.text[p.pos:p.pos+len(lit)] == lit {
+			n := &node{kind: kindValue, value: []interface{}{true, false, nil}[i], start: p.pos, end: p.pos + len(lit)}
+			p.pos = n.end
+			return n, nil
+		}
+	}
+
+	r, _ := utf8.DecodeRuneInString(p.text[p.pos:])
+	return nil, p.error("unexpected " + strconv.QuoteRune(r))
+}
+
+func (p *parser) array() (*node, error) {
+	n := &node{kind: kindArray, start: p.pos}
+	p.pos++
+
+	for {
+		p.skip()
+		if p.pos >= len(p.text) {
+			return nil, p.error("unexpected end of input")
+		}
+		if p.text[p.pos] == ']' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+
+		if len(n.items) > 0 {
+			if p.text[p.pos] != ',' {
+				return nil, p.error("expect ',' or ']'")
+			}
+			p.pos++
+			p.skip()
+		}
+
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+	}
+}
+
+func (p *parser) object() (*node, error) {
+	n := &node{kind: kindObject, start: p.pos}
+	p.pos++
+
+	for {
+		p.skip()
+		if p.pos >= len(p.text) {
+			return nil, p.error("unexpected end of input")
+		}
+		if p.text[p.pos] == '}' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+
+		if len(n.keys) > 0 {
+			if p.text[p.pos] != ',' {
+				return nil, p.error("expect ',' or '}'")
+			}
+			p.pos++
+			p.skip()
+		}
+
+		if p.pos >= len(p.text) || p.text[p.pos] != '"' {
+			return nil, p.error("expect a string key")
+		}
+		key, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skip()
+		if p.pos >= len(p.text) || p.text[p.pos] != ':' {
+			return nil, p.error("expect ':'")
+		}
+		p.pos++
+		p.skip()
+
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, key)
+		n.items = append(n.items, val)
+	}
+}
+
+func (p *parser) string() (*node, error) {
+	start := p.pos
+	p.pos++
+
+	for p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			var s string
+			if err := json.Unmarshal([]byte(p.text[start:p.pos]), &s); err != nil {
+				p.pos = start
+				return nil, p.error("invalid string")
+			}
+			return &node{kind: kindString, value: s, start: start, end: p.pos}, nil
+		case '\n':
+			return nil, p.error("unterminated string")
+		default:
+			p.pos++
+		}
+	}
+
+	return nil, p.error("unexpected end of input")
+}
+
+func (p *parser) number() (*node, error) {
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E' || c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	f, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.error("invalid number")
+	}
+	return &node{kind: kindValue, value: f, start: start, end: p.pos}, nil
+}
+
+// document the text of a file, positions of the protocol are
+// zero-based lines and utf-16 code unit columns
+type document struct {
+	text  string
+	lines []int
+}
+
+func newDocument(text string) *document {
+	d := &document{text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	return d
+}
+
+func (d *document) position(offset int) Position {
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	char := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		char += len(utf16.Encode([]rune{r}))
+	}
+	return Position{Line: line, Character: char}
+}
+
+func (d *document) offset(p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	offset := d.lines[p.Line]
+	char := 0
+	for char < p.Character && offset < len(d.text) {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		char += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
@@ -0,0 +1,340 @@
+// Package lsp is a language server for the gisp json scripts.
+// The names are checked against a sandbox, the lib special forms such as def, fn, for and switch
+// are recognized by their functions, so they work under any names.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ysmood/gisp"
+)
+
+// MaxContentLength the max size of the body of a message
+var MaxContentLength = 1 << 25
+
+// Server a language server for gisp json scripts, it provides diagnostics,
+// hover docs, completion of function names and go to the definitions of the names bound by def
+type Server struct {
+	// Sandbox the names available to the scripts
+	Sandbox *gisp.Sandbox
+
+	// Docs the documentation of the names that are not lib functions
+	Docs map[string]string
+
+	files map[string]*file
+	out   io.Writer
+}
+
+type file struct {
+	uri      string
+	doc      *document
+	analysis *analysis
+}
+
+// New creates a server with the sandbox
+func New(sandbox *gisp.Sandbox) *Server {
+	return &Server{Sandbox: sandbox, Docs: map[string]string{}}
+}
+
+// Serve reads the requests from the in and writes the responses to the out,
+// it returns nil when the client sends exit or closes the in
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.files = map[string]*file{}
+	s.out = out
+
+	r := textproto.NewReader(bufio.NewReader(in))
+
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		size, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil || size < 0 {
+			return fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+		}
+		if size > MaxContentLength {
+			return fmt.Errorf("Content-Length %d exceeds the max %d", size, MaxContentLength)
+		}
+
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r.R, body); err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.write(errorResponse{"2.0", nil, responseError{codeParseError, err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			return nil
+		}
+
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req *request) error {
+	handler, has := s.handlers()[req.Method]
+	if !has {
+		if req.ID == nil {
+			// the unknown notifications can be ignored
+			return nil
+		}
+		return s.write(errorResponse{"2.0", req.ID, responseError{codeMethodNotFound, "method not found: " + req.Method}})
+	}
+
+	result, err := handler(req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return s.write(errorResponse{"2.0", req.ID, responseError{codeInvalidParams, err.Error()}})
+	}
+	return s.write(response{"2.0", req.ID, result})
+}
+
+func (s *Server) handlers() map[string]func(json.RawMessage) (interface{}, error) {
+	return map[string]func(json.RawMessage) (interface{}, error){
+		"initialize":              s.initialize,
+		"initialized":             noop,
+		"shutdown":                noop,
+		"textDocument/didOpen":    s.didOpen,
+		"textDocument/didChange":  s.didChange,
+		"textDocument/didClose":   s.didClose,
+		"textDocument/hover":      s.hover,
+		"textDocument/completion": s.completion,
+		"textDocument/definition": s.definition,
+	}
+}
+
+func noop(json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *Server) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (s *Server) initialize(json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":   1,
+			"hoverProvider":      true,
+			"definitionProvider": true,
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"[", "\""},
+			},
+		},
+		"serverInfo": map[string]interface{}{"name": "gisp"},
+	}, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p didOpenParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p didChangeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	// the server only accepts the full sync
+	return nil, s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p didCloseParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.files, p.TextDocument.URI)
+	return nil, s.publish(p.TextDocument.URI, []Diagnostic{})
+}
+
+// update analyzes the text and publishes the diagnostics
+func (s *Server) update(uri, text string) error {
+	f := &file{uri: uri, doc: newDocument(text), analysis: analyze(text, s.Sandbox)}
+	s.files[uri] = f
+
+	list := []Diagnostic{}
+	for _, d := range f.analysis.diagnostics {
+		list = append(list, Diagnostic{
+			Range:    f.doc.rangeOf(d.start, d.end),
+			Severity: SeverityError,
+			Source:   "gisp",
+			Message:  d.message,
+		})
+	}
+	return s.publish(uri, list)
+}
+
+func (s *Server) publish(uri string, list []Diagnostic) error {
+	return s.write(notification{"2.0", "textDocument/publishDiagnostics", publishDiagnosticsParams{uri, list}})
+}
+
+// lookup returns the file and the offset of the position
+func (s *Server) lookup(params json.RawMessage) (*file, int, error) {
+	var p textDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, 0, err
+	}
+	f, has := s.files[p.TextDocument.URI]
+	if !has {
+		return nil, 0, fmt.Errorf("document not opened: %s", p.TextDocument.URI)
+	}
+	return f, f.doc.offset(p.Position), nil
+}
+
+// callAt returns the call whose head contains the offset
+func (f *file) callAt(offset int) (*node, *call) {
+	n := f.analysis.root.find(offset)
+	if n == nil {
+		return nil, nil
+	}
+	return n, f.analysis.calls[n]
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	f, offset, err := s.lookup(params)
+	if err != nil {
+		return nil, err
+	}
+
+	head, c := f.callAt(offset)
+	if c == nil {
+		return nil, nil
+	}
+
+	var text string
+	if c.def != nil {
+		pos := f.doc.position(c.def.start)
+		text = fmt.Sprintf("`%s` is bound at line %d, column %d", c.name, pos.Line+1, pos.Character+1)
+	} else {
+		text = doc(s.Sandbox, s.Docs, c.name)
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: text},
+		Range:    f.doc.rangeOf(head.start, head.end),
+	}, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	f, offset, err := s.lookup(params)
+	if err != nil {
+		return nil, err
+	}
+
+	_, c := f.callAt(offset)
+	if c == nil || c.def == nil {
+		return nil, nil
+	}
+
+	return Location{URI: f.uri, Range: f.doc.rangeOf(c.def.start, c.def.end)}, nil
+}
+
+// completion completes the function name at the head of an array, it only looks at the text
+// before the cursor, so it works when the document is incomplete
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	f, offset, err := s.lookup(params)
+	if err != nil {
+		return nil, err
+	}
+	text := f.doc.text
+
+	start := offset
+	for start > 0 && !strings.ContainsRune("\"[]{},: \t\r\n", rune(text[start-1])) {
+		start--
+	}
+
+	quoted := start > 0 && text[start-1] == '"'
+	bracket := start
+	if quoted {
+		bracket--
+	} else if start != offset {
+		return []CompletionItem{}, nil
+	}
+	for bracket > 0 && strings.ContainsRune(" \t\r\n", rune(text[bracket-1])) {
+		bracket--
+	}
+	if bracket == 0 || text[bracket-1] != '[' {
+		return []CompletionItem{}, nil
+	}
+
+	names := map[string]*node{}
+	for _, name := range s.Sandbox.Names() {
+		names[name] = nil
+	}
+	if arr := arrayAt(f.analysis.root, offset); arr != nil {
+		names = f.analysis.names(arr)
+	}
+
+	list := []CompletionItem{}
+	for name, def := range names {
+		escaped, _ := json.Marshal(name)
+		item := CompletionItem{
+			Label:    name,
+			Kind:     completionFunction,
+			TextEdit: &TextEdit{Range: f.doc.rangeOf(start, offset), NewText: string(escaped)},
+		}
+		if quoted {
+			item.TextEdit.NewText = string(escaped[1 : len(escaped)-1])
+		}
+		if def != nil {
+			item.Kind = completionVariable
+			item.Detail = "bound in the script"
+		} else {
+			item.Documentation = doc(s.Sandbox, s.Docs, name)
+		}
+		list = append(list, item)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Label < list[j].Label })
+	return list, nil
+}
+
+// arrayAt returns the deepest array that contains the offset
+func arrayAt(n *node, offset int) *node {
+	if n == nil || n.kind != kindArray || offset <= n.start || offset >= n.end {
+		return nil
+	}
+	for _, child := range n.items {
+		if found := arrayAt(child, offset); found != nil {
+			return found
+		}
+	}
+	return n
+}
@@ -0,0 +1,213 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+	"github.com/ysmood/gisp/lsp"
+)
+
+const uri = "file:///a.json"
+
+func sandbox() *gisp.Sandbox {
+	return gisp.New(gisp.Box{
+		"$":      lib.Raw,
+		"+":      lib.Add,
+		"get":    lib.Get,
+		"def":    lib.Def,
+		"do":     lib.Do,
+		"if":     lib.If,
+		"fn":     lib.Fn,
+		"for":    lib.For,
+		"switch": lib.Switch,
+	})
+}
+
+// session sends the messages to the server, returns the messages from the server
+func session(t *testing.T, server *lsp.Server, msgs ...interface{}) []map[string]interface{} {
+	var in, out bytes.Buffer
+	for _, msg := range msgs {
+		data, _ := json.Marshal(msg)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+
+	assert.Nil(t, server.Serve(&in, &out))
+
+	list := []map[string]interface{}{}
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			return list
+		}
+		assert.Nil(t, err)
+		size, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, size)
+		_, _ = io.ReadFull(r.R, body)
+
+		var msg map[string]interface{}
+		assert.Nil(t, json.Unmarshal(body, &msg))
+		list = append(list, msg)
+	}
+}
+
+func open(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "textDocument/didOpen",
+		"params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "text": text},
+		},
+	}
+}
+
+func at(id int, method string, line, char int) map[string]interface{} {
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"position":     map[string]interface{}{"line": line, "character": char},
+		},
+	}
+}
+
+func diagnostics(msg map[string]interface{}) []string {
+	list := []string{}
+	for _, d := range msg["params"].(map[string]interface{})["diagnostics"].([]interface{}) {
+		d := d.(map[string]interface{})
+		start := d["range"].(map[string]interface{})["start"].(map[string]interface{})
+		list = append(list, fmt.Sprintf("%v:%v %v", start["line"], start["character"], d["message"]))
+	}
+	return list
+}
+
+func TestDiagnostics(t *testing.T) {
+	out := session(t, lsp.New(sandbox()), open(`["do",
+  ["def", "f", ["fn", ["a"], ["+", ["a"], ["b"]]]],
+  ["for", "k", "v", ["$", [1]], ["f", ["v"]]],
+  ["if", true],
+  ["get", 1],
+  ["switch", 1, ["x", 1, 2]],
+  ["a"],
+  [1]
+]`))
+
+	assert.Len(t, out, 1)
+	assert.Equal(t, "textDocument/publishDiagnostics", out[0]["method"])
+	assert.Equal(t, []string{
+		`1:43 "b" is undefined`,
+		`3:2 "if" expects 2 to 3 arguments, got 1`,
+		`4:2 "get" expects 2 to 3 arguments, got 1`,
+		`5:16 switch expects ["case", value, body] or ["default", body] as the last one`,
+		`6:3 "a" is undefined`,
+		`7:3 the function name must be a string`,
+	}, diagnostics(out[0]))
+}
+
+func TestDiagnosticsSyntax(t *testing.T) {
+	out := session(t, lsp.New(sandbox()), open("[\"do\",\n  1 2]"))
+	assert.Equal(t, []string{`1:4 expect ',' or ']'`}, diagnostics(out[0]))
+}
+
+func doc(fn func(*gisp.Context) interface{}) string {
+	d, _ := lib.Doc(fn)
+	return d
+}
+
+func TestHover(t *testing.T) {
+	server := lsp.New(sandbox())
+	server.Docs["+"] = "adds"
+
+	out := session(t, server,
+		open(`["do", ["def", "x", 1], ["get", ["x"]], ["+", 1]]`),
+		at(1, "textDocument/hover", 0, 27),
+		at(2, "textDocument/hover", 0, 34),
+		at(3, "textDocument/hover", 0, 43),
+		at(4, "textDocument/hover", 0, 1),
+	)
+
+	value := func(msg map[string]interface{}) interface{} {
+		return msg["result"].(map[string]interface{})["contents"].(map[string]interface{})["value"]
+	}
+	assert.Equal(t, doc(lib.Get), value(out[1]))
+	assert.Equal(t, "`x` is bound at line 1, column 16", value(out[2]))
+	assert.Equal(t, "adds", value(out[3]))
+	assert.Equal(t, doc(lib.Do), value(out[4]))
+}
+
+func TestDefinition(t *testing.T) {
+	out := session(t, lsp.New(sandbox()),
+		open("[\"do\",\n  [\"def\", \"x\", 1],\n  [\"x\"]]"),
+		at(1, "textDocument/definition", 2, 4),
+		at(2, "textDocument/definition", 0, 2),
+	)
+
+	assert.Equal(t, map[string]interface{}{
+		"uri": uri,
+		"range": map[string]interface{}{
+			"start": map[string]interface{}{"line": float64(1), "character": float64(10)},
+			"end":   map[string]interface{}{"line": float64(1), "character": float64(13)},
+		},
+	}, out[1]["result"])
+	assert.Nil(t, out[2]["result"])
+}
+
+func TestCompletion(t *testing.T) {
+	labels := func(msg map[string]interface{}) []string {
+		list := []string{}
+		for _, item := range msg["result"].([]interface{}) {
+			list = append(list, item.(map[string]interface{})["label"].(string))
+		}
+		return list
+	}
+
+	out := session(t, lsp.New(sandbox()),
+		open(`["fn", ["ab"], ["a"]]`),
+		at(1, "textDocument/completion", 0, 18),
+		at(2, "textDocument/completion", 0, 1),
+		at(3, "textDocument/completion", 0, 7),
+	)
+
+	assert.Equal(t, []string{"$", "+", "ab", "def", "do", "fn", "for", "get", "if", "switch"}, labels(out[1]))
+	edit := out[1]["result"].([]interface{})[2].(map[string]interface{})["textEdit"].(map[string]interface{})
+	assert.Equal(t, "ab", edit["newText"])
+	assert.Len(t, labels(out[2]), 9)
+	assert.Len(t, labels(out[3]), 0)
+}
+
+func TestUnknownMethod(t *testing.T) {
+	out := session(t, lsp.New(sandbox()),
+		map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "foo"},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "$/foo"},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 2, "method": "initialize"},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "exit"},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 3, "method": "shutdown"},
+	)
+
+	assert.Len(t, out, 2)
+	assert.Equal(t, float64(-32601), out[0]["error"].(map[string]interface{})["code"])
+	assert.Equal(t, true, out[1]["result"].(map[string]interface{})["capabilities"].(map[string]interface{})["hoverProvider"])
+}
+
+func TestContentLength(t *testing.T) {
+	serve := func(length string) error {
+		in := bytes.NewBufferString("Content-Length: " + length + "\r\n\r\n{}")
+		return lsp.New(sandbox()).Serve(in, io.Discard)
+	}
+
+	assert.EqualError(t, serve("-1"), `invalid Content-Length: "-1"`)
+	assert.EqualError(t, serve("x"), `invalid Content-Length: "x"`)
+	assert.EqualError(t, serve(strconv.Itoa(lsp.MaxContentLength+1)),
+		fmt.Sprintf("Content-Length %d exceeds the max %d", lsp.MaxContentLength+1, lsp.MaxContentLength))
+}
@@ -0,0 +1,418 @@
+// Package msgpack encodes gisp AST into MessagePack, it's much faster to load than json.
+// Only the types of the AST contract are supported, integral numbers are stored as
+// the smallest MessagePack integer and decoded back to float64.
+// The decimals are stored as the ext type DecimalExt with their string form,
+// use Decoder.Number to decode the numbers into the exact model.
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// DecimalExt the MessagePack ext type of the decimals
+const DecimalExt = 1
+
+// Encode encodes the AST, map keys are sorted so the output is stable
+func Encode(ast interface{}) ([]byte, error) {
+	e := &encoder{}
+	if err := e.value(ast); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) value(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case float64:
+		e.float(v)
+	case int64:
+		e.int(v)
+	case decimal:
+		e.ext(DecimalExt, v.String())
+	case string:
+		e.str(v)
+	case []interface{}:
+		e.header(len(v), 0x90, 0xdc, 0xdd)
+		for _, el := range v {
+			if err := e.value(el); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		e.header(len(v), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			e.str(k)
+			if err := e.value(v[k]); err != nil {
+				return err
+			}
+		}
+	case ordered:
+		keys := v.Keys()
+		e.header(len(keys), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			e.str(k)
+			val, _ := v.Get(k)
+			if err := e.value(val); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+func (e *encoder) float(f float64) {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && !(f == 0 && math.Signbit(f)) {
+		e.int(int64(f))
+		return
+	}
+
+	if float64(float32(f)) == f {
+		e.buf = append(e.buf, 0xca)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(f)))
+		return
+	}
+
+	e.buf = append(e.buf, 0xcb)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(f))
+}
+
+func (e *encoder) int(i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		e.buf = append(e.buf, byte(i))
+	case i < 0 && i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(i))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(i))
+	}
+}
+
+func (e *encoder) ext(typ byte, data string) {
+	switch l := len(data); {
+	case l <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc7, byte(l))
+	case l <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc8)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(l))
+	default:
+		e.buf = append(e.buf, 0xc9)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(l))
+	}
+	e.buf = append(e.buf, typ)
+	e.buf = append(e.buf, data...)
+}
+
+func (e *encoder) str(s string) {
+	switch l := len(s); {
+	case l < 32:
+		e.buf = append(e.buf, 0xa0|byte(l))
+	case l <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(l))
+	case l <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(l))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(l))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) header(l int, fix, b16, b32 byte) {
+	switch {
+	case l < 16:
+		e.buf = append(e.buf, fix|byte(l))
+	case l <= math.MaxUint16:
+		e.buf = append(e.buf, b16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(l))
+	default:
+		e.buf = append(e.buf, b32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(l))
+	}
+}
+
+// decimal is the arbitrary-precision number, such as gisp.Decimal
+type decimal interface {
+	Rat() *big.Rat
+	String() string
+}
+
+// ordered is the dict that keeps the order of its keys, such as *gisp.Dict,
+// its keys are encoded in order
+type ordered interface {
+	Keys() []string
+	Get(key string) (interface{}, bool)
+}
+
+// ErrUnexpectedEnd the data is truncated
+var ErrUnexpectedEnd = errors.New("unexpected end of data")
+
+// Decode decodes the data into AST with the default options
+func Decode(data []byte) (interface{}, error) {
+	return Decoder{}.Decode(data)
+}
+
+// Decoder the options to decode
+type Decoder struct {
+	// Number converts the integers and decimals from their decimal string form,
+	// they are float64 if it's nil
+	Number func(s string) (interface{}, error)
+
+	// Dict creates the dict from the keys and values in the order of the data,
+	// the dicts are map[string]interface{} if it's nil
+	Dict func(keys []string, vals []interface{}) interface{}
+}
+
+// Decode decodes the data into AST
+func (opts Decoder) Decode(data []byte) (interface{}, error) {
+	d := &decoder{Decoder: opts, data: data}
+
+	ast, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("invalid data after the top-level value at %d", d.pos)
+	}
+	return ast, nil
+}
+
+type decoder struct {
+	Decoder
+	data []byte
+	pos  int
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, ErrUnexpectedEnd
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (d *decoder) value() (interface{}, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return d.int(int64(c), nil)
+	case c >= 0xe0:
+		return d.int(int64(int8(c)), nil)
+	case c&0xf0 == 0x80:
+		return d.dict(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce:
+		v, err := d.uint(1 << (c - 0xcc))
+		return d.int(int64(v), err)
+	case 0xcf:
+		v, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return d.number(strconv.FormatUint(v, 10))
+	case 0xd0:
+		v, err := d.uint(1)
+		return d.int(int64(int8(v)), err)
+	case 0xd1:
+		v, err := d.uint(2)
+		return d.int(int64(int16(v)), err)
+	case 0xd2:
+		v, err := d.uint(4)
+		return d.int(int64(int32(v)), err)
+	case 0xd3:
+		v, err := d.uint(8)
+		return d.int(int64(v), err)
+	case 0xc7, 0xc8, 0xc9:
+		l, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(int(l))
+	case 0xd9, 0xda, 0xdb:
+		l, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(l))
+	case 0xdc, 0xdd:
+		l, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(l))
+	case 0xde, 0xdf:
+		l, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.dict(int(l))
+	}
+
+	return nil, fmt.Errorf("unsupported type 0x%x at %d", c, d.pos-1)
+}
+
+func (d *decoder) int(v int64, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	if d.Number == nil {
+		return float64(v), nil
+	}
+	return d.Number(strconv.FormatInt(v, 10))
+}
+
+func (d *decoder) number(s string) (interface{}, error) {
+	if d.Number == nil {
+		return strconv.ParseFloat(s, 64)
+	}
+	return d.Number(s)
+}
+
+func (d *decoder) ext(l int) (interface{}, error) {
+	pos := d.pos
+	typ, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.read(l)
+	if err != nil {
+		return nil, err
+	}
+	if typ[0] != DecimalExt {
+		return nil, fmt.Errorf("unsupported ext type %d at %d", typ[0], pos)
+	}
+	return d.number(string(data))
+}
+
+func (d *decoder) str(l int) (interface{}, error) {
+	b, err := d.read(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) array(l int) (interface{}, error) {
+	// each item takes at least one byte
+	if l > len(d.data)-d.pos {
+		return nil, ErrUnexpectedEnd
+	}
+
+	arr := make([]interface{}, l)
+	for i := range arr {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (d *decoder) dict(l int) (interface{}, error) {
+	if l*2 > len(d.data)-d.pos {
+		return nil, ErrUnexpectedEnd
+	}
+
+	keys := make([]string, l)
+	vals := make([]interface{}, l)
+	for i := 0; i < l; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("dict key must be a string at %d", d.pos)
+		}
+
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		keys[i], vals[i] = key, v
+	}
+
+	if d.Dict != nil {
+		return d.Dict(keys, vals), nil
+	}
+
+	dict := make(map[string]interface{}, l)
+	for i, k := range keys {
+		dict[k] = vals[i]
+	}
+	return dict, nil
+}
@@ -0,0 +1,101 @@
+package msgpack_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/a8m/djson"
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/msgpack"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ast, _ := djson.Decode([]byte(`["do",
+		["def", "a", {"b": [1, -1, -33, 200, -200, 70000, -70000, 5000000000, 1.5, 0.1, 1e300]}],
+		[null, true, false, "", "` + strings.Repeat("s", 40) + `", "` + strings.Repeat("s", 300) + `"],
+		{"` + strings.Repeat("k", 70000) + `": {}}
+	]`))
+
+	data, err := msgpack.Encode(ast)
+	assert.Nil(t, err)
+
+	out, err := msgpack.Decode(data)
+	assert.Nil(t, err)
+	assert.Equal(t, ast, out)
+}
+
+func TestLargeCollection(t *testing.T) {
+	arr := make([]interface{}, 70000)
+	dict := map[string]interface{}{}
+	for i := range arr {
+		arr[i] = float64(i)
+	}
+	for i := 0; i < 20; i++ {
+		dict[strings.Repeat("a", i)] = float64(i)
+	}
+
+	data, _ := msgpack.Encode([]interface{}{arr, dict, -math.MaxFloat64})
+	out, err := msgpack.Decode(data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{arr, dict, -math.MaxFloat64}, out)
+}
+
+func TestCompact(t *testing.T) {
+	data, _ := msgpack.Encode([]interface{}{"+", float64(1), float64(2)})
+	assert.Equal(t, []byte{0x93, 0xa1, '+', 0x01, 0x02}, data)
+}
+
+func TestErr(t *testing.T) {
+	_, err := msgpack.Encode([]interface{}{1})
+	assert.EqualError(t, err, "unsupported type int")
+
+	_, err = msgpack.Decode([]byte{0x93, 0x01})
+	assert.Equal(t, msgpack.ErrUnexpectedEnd, err)
+
+	_, err = msgpack.Decode([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	assert.Equal(t, msgpack.ErrUnexpectedEnd, err)
+
+	_, err = msgpack.Decode([]byte{0x81, 0x01, 0x01})
+	assert.EqualError(t, err, "dict key must be a string at 2")
+
+	_, err = msgpack.Decode([]byte{0xc1})
+	assert.EqualError(t, err, "unsupported type 0xc1 at 0")
+
+	_, err = msgpack.Decode([]byte{0x01, 0x01})
+	assert.EqualError(t, err, "invalid data after the top-level value at 1")
+}
+
+func TestOrdered(t *testing.T) {
+	d := gisp.NewDict()
+	d.Set("b", 1.0)
+	d.Set("a", 2.0)
+
+	data, _ := msgpack.Encode(d)
+	assert.Equal(t, []byte{0x82, 0xa1, 'b', 0x01, 0xa1, 'a', 0x02}, data)
+}
+
+func TestExact(t *testing.T) {
+	d, _ := gisp.NewDecimal("0.1")
+	big, _ := gisp.NewDecimal("18446744073709551616")
+	ast := []interface{}{int64(1), int64(-70000), int64(math.MaxInt64), d, big, 1.5}
+
+	data, err := msgpack.Encode(ast)
+	assert.Nil(t, err)
+
+	out, err := gisp.Binary{Exact: true}.Decode(data)
+	assert.Nil(t, err)
+	assert.Equal(t, ast, out)
+
+	out, err = msgpack.Decode(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{1.0, -70000.0, float64(math.MaxInt64), 0.1, 18446744073709551616.0, 1.5}, out)
+
+	out, _ = gisp.Binary{Exact: true}.Decode([]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	assert.Equal(t, "18446744073709551615", out.(gisp.Decimal).String())
+
+	_, err = msgpack.Decode([]byte{0xc7, 0x01, 0x05, 0x00})
+	assert.EqualError(t, err, "unsupported ext type 5 at 2")
+}
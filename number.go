@@ -0,0 +1,181 @@
+package gisp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxDecimalExp the max absolute exponent allowed when parsing decimals,
+// such as 1e1000, it prevents huge numbers from exhausting the memory
+var MaxDecimalExp = 1000
+
+// Numbers in gisp are float64, int64 or Decimal.
+// The decoders produce float64 by default, the int64 and Decimal form the exact
+// numeric model, see ExactNumber. The values from host are converted by ToNumber
+// when they are put into the Sandbox. The lib treats numbers that have the same
+// value as equal, regardless of their types.
+
+// Decimal an arbitrary-precision decimal number, it's immutable.
+type Decimal struct {
+	rat *big.Rat
+}
+
+// NewDecimal parses the decimal string, such as "0.1", "-12.5e3"
+func NewDecimal(s string) (Decimal, error) {
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > MaxDecimalExp || exp < -MaxDecimalExp {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{rat: r}, nil
+}
+
+// DecimalFromRat creates a decimal from the copy of r
+func DecimalFromRat(r *big.Rat) Decimal {
+	return Decimal{rat: new(big.Rat).Set(r)}
+}
+
+// Rat returns the copy of the value
+func (d Decimal) Rat() *big.Rat {
+	if d.rat == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(d.rat)
+}
+
+// Float64 returns the nearest float64 value
+func (d Decimal) Float64() float64 {
+	if d.rat == nil {
+		return 0
+	}
+	f, _ := d.rat.Float64()
+	return f
+}
+
+// String returns the shortest decimal form, such as "0.3"
+func (d Decimal) String() string {
+	if d.rat == nil {
+		return "0"
+	}
+	if d.rat.IsInt() {
+		return d.rat.Num().String()
+	}
+
+	// a finite decimal's denominator only has the factors 2 and 5,
+	// the count of the decimal places is the max power of them
+	denom := new(big.Int).Set(d.rat.Denom())
+	mod := new(big.Int)
+	places := map[int64]int{2: 0, 5: 0}
+	for _, f := range []int64{2, 5} {
+		factor := big.NewInt(f)
+		for {
+			q, m := new(big.Int).QuoRem(denom, factor, mod)
+			if m.Sign() != 0 {
+				break
+			}
+			denom = q
+			places[f]++
+		}
+	}
+
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		// not a finite decimal
+		return strings.TrimRight(d.rat.FloatString(34), "0")
+	}
+
+	n := places[2]
+	if places[5] > n {
+		n = places[5]
+	}
+	return d.rat.FloatString(n)
+}
+
+// MarshalJSON outputs the decimal as a json number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// ExactNumber converts the json.Number to the exact numeric model,
+// the integers that fit int64 become int64, others become Decimal.
+// Use it with the JSON decoder:
+//
+//	gisp.JSON{UseNumber: true, Number: gisp.ExactNumber}
+func ExactNumber(n json.Number) (interface{}, error) {
+	if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		return i, nil
+	}
+
+	d, err := NewDecimal(n.String())
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeExact(d.rat), nil
+}
+
+// NormalizeExact returns int64 if the r is an integer that fits int64, or a Decimal
+func NormalizeExact(r *big.Rat) interface{} {
+	if r.IsInt() && r.Num().IsInt64() {
+		return r.Num().Int64()
+	}
+	return DecimalFromRat(r)
+}
+
+// ToNumber converts the Go numeric types to the numeric types of gisp.
+// The float32 becomes float64, other integer types become int64,
+// or Decimal if the value overflows int64. Other values are returned as they are.
+func ToNumber(val interface{}) interface{} {
+	switch v := val.(type) {
+	case float64, int64, Decimal:
+		return val
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint:
+		return uintToNumber(uint64(v))
+	case uint64:
+		return uintToNumber(v)
+	case float32:
+		return float64(v)
+	default:
+		return val
+	}
+}
+
+func uintToNumber(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return Decimal{rat: new(big.Rat).SetInt(new(big.Int).SetUint64(v))}
+	}
+	return int64(v)
+}
+
+// IsNumber checks if the value is one of the numeric types of gisp:
+// float64, int64 or Decimal
+func IsNumber(val interface{}) bool {
+	switch val.(type) {
+	case float64, int64, Decimal:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,58 @@
+package gisp_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+)
+
+func TestExactNumber(t *testing.T) {
+	ast, err := gisp.JSON{UseNumber: true, Number: gisp.ExactNumber}.Decode(
+		[]byte(`[9007199254740993, 0.1, 1.0, 1e2, 12345678901234567890]`),
+	)
+	assert.Nil(t, err)
+
+	arr := ast.([]interface{})
+	assert.Equal(t, int64(9007199254740993), arr[0])
+	assert.Equal(t, "0.1", arr[1].(gisp.Decimal).String())
+	assert.Equal(t, int64(1), arr[2])
+	assert.Equal(t, int64(100), arr[3])
+	assert.Equal(t, "12345678901234567890", arr[4].(gisp.Decimal).String())
+
+	data, _ := json.Marshal(ast)
+	assert.Equal(t, `[9007199254740993,0.1,1,100,12345678901234567890]`, string(data))
+}
+
+func TestDecimal(t *testing.T) {
+	d, err := gisp.NewDecimal("-12.50e-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "-1.25", d.String())
+	assert.Equal(t, -1.25, d.Float64())
+
+	_, err = gisp.NewDecimal("1e100000")
+	assert.EqualError(t, err, `invalid decimal "1e100000"`)
+
+	_, err = gisp.NewDecimal("x")
+	assert.EqualError(t, err, `invalid decimal "x"`)
+
+	assert.Equal(t, "0", gisp.Decimal{}.String())
+	assert.Equal(t, "0.3333333333333333333333333333333333", gisp.DecimalFromRat(big.NewRat(1, 3)).String())
+}
+
+func TestToNumber(t *testing.T) {
+	assert.Equal(t, int64(1), gisp.ToNumber(1))
+	assert.Equal(t, int64(1), gisp.ToNumber(uint8(1)))
+	assert.Equal(t, 0.5, gisp.ToNumber(float32(0.5)))
+	assert.Equal(t, "18446744073709551615", gisp.ToNumber(^uint64(0)).(gisp.Decimal).String())
+	assert.Equal(t, "a", gisp.ToNumber("a"))
+
+	sandbox := gisp.New(gisp.Box{"a": 1, "b": "b"})
+	sandbox.Reset("c", uint16(2))
+	a, _ := sandbox.Get("a")
+	c, _ := sandbox.Get("c")
+	assert.Equal(t, int64(1), a)
+	assert.Equal(t, int64(2), c)
+}
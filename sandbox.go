@@ -1,5 +1,10 @@
 package gisp
 
+import (
+	"fmt"
+	"reflect"
+)
+
 // Box which contains the raw userspace values
 type Box map[string]interface{}
 
@@ -10,10 +15,17 @@ type Sandbox struct {
 	parent *Sandbox
 }
 
-// New create a new sandbox
+// New create a new sandbox, the numbers in the dict will be converted by ToNumber,
+// the Go funcs will be wrapped by Bind
+// the dict is copied, so it's safe to share the same dict between sandboxes
 func New(dict Box) *Sandbox {
+	box := make(Box, len(dict))
+	for k, v := range dict {
+		box[k] = sandboxValue(k, v)
+	}
+
 	return &Sandbox{
-		dict: dict,
+		dict: box,
 	}
 }
 
@@ -72,7 +84,7 @@ func (sandbox *Sandbox) Box() Box {
 
 // Set set property
 func (sandbox *Sandbox) Set(name string, val interface{}) {
-	sandbox.dict[name] = val
+	sandbox.dict[name] = sandboxValue(name, val)
 }
 
 // Reset set property
@@ -80,6 +92,7 @@ func (sandbox *Sandbox) Set(name string, val interface{}) {
 // will be created on current closure
 func (sandbox *Sandbox) Reset(name string, val interface{}) {
 	curr := sandbox
+	val = sandboxValue(name, val)
 
 	for sandbox != nil {
 		_, has := sandbox.dict[name]
@@ -94,3 +107,35 @@ func (sandbox *Sandbox) Reset(name string, val interface{}) {
 
 	curr.dict[name] = val
 }
+
+// hostValue converts the value from host, see ToNumber and Bind,
+// the funcs that Bind doesn't support are returned as they are with the reason
+func hostValue(val interface{}) (interface{}, error) {
+	switch val.(type) {
+	// the values of the scripts need no conversion, so the common path is free of reflection
+	case nil, bool, string, float64, int64, Decimal,
+		[]interface{}, map[string]interface{}, *Dict, func(*Context) interface{}:
+		return val, nil
+	}
+
+	if t := reflect.TypeOf(val); t.Kind() == reflect.Func {
+		if err := Bindable(t); err != nil {
+			return val, err
+		}
+		return Bind(val), nil
+	}
+	return ToNumber(val), nil
+}
+
+// sandboxValue converts the value put into the sandbox under the name, the funcs
+// that Bind doesn't support become functions that stop the run when they are called
+func sandboxValue(name string, val interface{}) interface{} {
+	v, err := hostValue(val)
+	if err != nil {
+		return func(ctx *Context) interface{} {
+			ctx.Error(fmt.Sprintf("function %q can't be called: %s", name, err))
+			return nil
+		}
+	}
+	return v
+}
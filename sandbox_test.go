@@ -21,9 +21,9 @@ func TestNewClosure(t *testing.T) {
 	val2, _ := newSandbox.Get("foo")
 	val3, _ := newSandbox.Get("bar")
 
-	assert.Equal(t, 1, val1)
-	assert.Equal(t, 2, val2)
-	assert.Equal(t, 3, val3)
+	assert.Equal(t, int64(1), val1)
+	assert.Equal(t, int64(2), val2)
+	assert.Equal(t, int64(3), val3)
 }
 
 func TestDeepClosure(t *testing.T) {
@@ -36,15 +36,15 @@ func TestDeepClosure(t *testing.T) {
 	c4 := c3.Create()
 
 	val, _ := c4.Get("foo")
-	assert.Equal(t, 1, val)
+	assert.Equal(t, int64(1), val)
 
 	c4.Set("foo", 2)
 	val2, _ := c1.Get("foo")
-	assert.Equal(t, 1, val2)
+	assert.Equal(t, int64(1), val2)
 
 	c3.Reset("foo", 2)
 	val3, _ := c1.Get("foo")
-	assert.Equal(t, 2, val3)
+	assert.Equal(t, int64(2), val3)
 
 }
 
@@ -67,11 +67,32 @@ func TestClosureNames(t *testing.T) {
 	c4.Set("e", 5)
 
 	assert.Equal(t, gisp.Box{
-		"e": 5,
-		"d": 4,
-		"b": 2,
-		"c": 3,
-		"a": 1,
+		"e": int64(5),
+		"d": int64(4),
+		"b": int64(2),
+		"c": int64(3),
+		"a": int64(1),
 	}, c4.Box())
 
 }
+
+func TestNewCopiesBox(t *testing.T) {
+	box := gisp.Box{"n": 1}
+
+	sandbox := gisp.New(box)
+	sandbox.Set("m", 2)
+
+	assert.Equal(t, gisp.Box{"n": 1}, box)
+	assert.Equal(t, gisp.Box{"n": int64(1), "m": int64(2)}, sandbox.Box())
+
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			gisp.New(box)
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+}
@@ -0,0 +1,365 @@
+// Package sexp reads and prints the S-expression form of gisp AST.
+//
+// Example: (for i item (arr) (append (list) (item)))
+//
+// A list is an array, a bare symbol is a string, dict literals use curly braces
+// such as {a 1 "b c" 2}, and comments start with ";" till the end of the line.
+package sexp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ysmood/gisp/internal/pos"
+)
+
+// Error parse error with position info
+type Error = pos.Error
+
+// Parse parses the code into the AST that can be run by gisp.Run with the default options
+func Parse(code []byte) (interface{}, error) {
+	return Parser{}.Parse(code)
+}
+
+// Parser the options to parse
+type Parser struct {
+	// Dict creates the dict from the keys and values in the order of the code,
+	// the dicts are map[string]interface{} if it's nil
+	Dict func(keys []string, vals []interface{}) interface{}
+}
+
+// Parse parses the code into the AST that can be run by gisp.Run
+func (opts Parser) Parse(code []byte) (interface{}, error) {
+	p := &parser{Parser: opts, code: code}
+
+	p.skip()
+	if p.eof() {
+		return nil, p.error("unexpected end of input")
+	}
+
+	ast, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skip()
+	if !p.eof() {
+		return nil, p.error("unexpected " + strconv.Quote(string(p.code[p.pos])))
+	}
+
+	return ast, nil
+}
+
+type parser struct {
+	Parser
+	code []byte
+	pos  int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.code)
+}
+
+func (p *parser) error(msg string) error {
+	return pos.New(string(p.code), p.pos, msg)
+}
+
+// skip whitespaces and comments
+func (p *parser) skip() {
+	for !p.eof() {
+		c := p.code[p.pos]
+		switch {
+		case c == ';':
+			for !p.eof() && p.code[p.pos] != '\n' {
+				p.pos++
+			}
+		case isSpace(c):
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) value() (interface{}, error) {
+	switch p.code[p.pos] {
+	case '(':
+		return p.list()
+	case '{':
+		return p.dict()
+	case '"':
+		return p.str()
+	case ')', '}', '[', ']':
+		return nil, p.error("unexpected " + strconv.Quote(string(p.code[p.pos])))
+	default:
+		return p.atom(), nil
+	}
+}
+
+func (p *parser) list() (interface{}, error) {
+	p.pos++
+	list := []interface{}{}
+
+	for {
+		p.skip()
+		if p.eof() {
+			return nil, p.error("missing \")\"")
+		}
+		if p.code[p.pos] == ')' {
+			p.pos++
+			return list, nil
+		}
+
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, val)
+	}
+}
+
+func (p *parser) dict() (interface{}, error) {
+	p.pos++
+	keys := []string{}
+	vals := []interface{}{}
+
+	for {
+		p.skip()
+		if p.eof() {
+			return nil, p.error("missing \"}\"")
+		}
+		if p.code[p.pos] == '}' {
+			p.pos++
+			return p.newDict(keys, vals), nil
+		}
+
+		key, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			return nil, p.error("dict key must be a symbol or string")
+		}
+
+		p.skip()
+		if p.eof() || p.code[p.pos] == '}' {
+			return nil, p.error("missing value of key " + strconv.Quote(k))
+		}
+
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		keys, vals = append(keys, k), append(vals, val)
+	}
+}
+
+func (p *parser) newDict(keys []string, vals []interface{}) interface{} {
+	if p.Dict != nil {
+		return p.Dict(keys, vals)
+	}
+
+	dict := make(map[string]interface{}, len(keys))
+	for i, k := range keys {
+		dict[k] = vals[i]
+	}
+	return dict
+}
+
+func (p *parser) str() (interface{}, error) {
+	start := p.pos
+	p.pos++
+
+	for !p.eof() {
+		switch p.code[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case '"':
+			p.pos++
+			var s string
+			if err := json.Unmarshal(p.code[start:p.pos], &s); err != nil {
+				p.pos = start
+				return nil, p.error("invalid string")
+			}
+			return s, nil
+		}
+		p.pos++
+	}
+
+	p.pos = start
+	return nil, p.error("unterminated string")
+}
+
+func (p *parser) atom() interface{} {
+	start := p.pos
+	for !p.eof() && !isDelimiter(p.code[p.pos]) {
+		p.pos++
+	}
+	token := string(p.code[start:p.pos])
+
+	switch token {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	if isNumber(token) {
+		f, err := strconv.ParseFloat(token, 64)
+		if err == nil {
+			return f
+		}
+	}
+
+	return token
+}
+
+// Print renders the AST as S-expression
+func Print(ast interface{}) string {
+	var b strings.Builder
+	write(&b, ast)
+	return b.String()
+}
+
+func write(b *strings.Builder, ast interface{}) {
+	switch v := ast.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case float64:
+		b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		b.WriteString(symbol(v))
+	case []interface{}:
+		b.WriteByte('(')
+		for i, el := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			write(b, el)
+		}
+		b.WriteByte(')')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(symbol(k))
+			b.WriteByte(' ')
+			write(b, v[k])
+		}
+		b.WriteByte('}')
+	case ordered:
+		b.WriteByte('{')
+		for i, k := range v.Keys() {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			val, _ := v.Get(k)
+			b.WriteString(symbol(k))
+			b.WriteByte(' ')
+			write(b, val)
+		}
+		b.WriteByte('}')
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			b.Write(data)
+		} else {
+			b.WriteString(symbol(fmt.Sprint(v)))
+		}
+	}
+}
+
+// ordered is the dict that keeps the order of its keys, such as *gisp.Dict
+type ordered interface {
+	Keys() []string
+	Get(key string) (interface{}, bool)
+}
+
+// symbol returns the string itself if it can be read back as the same string,
+// or the quoted string
+func symbol(s string) string {
+	if s == "" || s == "true" || s == "false" || s == "null" || isNumber(s) {
+		return quote(s)
+	}
+	for i := 0; i < len(s); i++ {
+		if isDelimiter(s[i]) || s[i] < 0x20 || s[i] == '\\' {
+			return quote(s)
+		}
+	}
+	return s
+}
+
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ','
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '{', '}', '[', ']', '"', ';':
+		return true
+	}
+	return isSpace(c)
+}
+
+// isNumber checks if the token follows the json number grammar
+func isNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	digits := func() int {
+		n := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+
+	if i < len(s) && s[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return false
+	}
+
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+
+	return i == len(s)
+}
@@ -0,0 +1,79 @@
+package sexp_test
+
+import (
+	"testing"
+
+	"github.com/a8m/djson"
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+	"github.com/ysmood/gisp/sexp"
+)
+
+func TestParse(t *testing.T) {
+	ast, err := sexp.Parse([]byte(`
+		; sum the list
+		(do
+			(def sum 0)
+			(for i el ($ (1 2.5 -3e2))
+				(redef sum (+ (sum) (el))))
+			{"a b" "c\n" d (x) n null t true f false})
+	`))
+
+	assert.Nil(t, err)
+
+	exp, _ := djson.Decode([]byte(`["do",
+		["def", "sum", 0],
+		["for", "i", "el", ["$", [1, 2.5, -3e2]],
+			["redef", "sum", ["+", ["sum"], ["el"]]]],
+		{"a b": "c\n", "d": ["x"], "n": null, "t": true, "f": false}
+	]`))
+	assert.Equal(t, exp, ast)
+}
+
+func TestParseErr(t *testing.T) {
+	_, err := sexp.Parse([]byte("(a\n  (b)"))
+	assert.EqualError(t, err, `2:6: missing ")"`)
+
+	_, err = sexp.Parse([]byte("(a) b"))
+	assert.EqualError(t, err, `1:5: unexpected "b"`)
+
+	_, err = sexp.Parse([]byte(`{a}`))
+	assert.EqualError(t, err, `1:3: missing value of key "a"`)
+
+	_, err = sexp.Parse([]byte(`("a)`))
+	assert.EqualError(t, err, `1:2: unterminated string`)
+}
+
+func TestPrint(t *testing.T) {
+	ast, _ := djson.Decode([]byte(`["if", ["==", ["a"], "1"], "a b", {"z": null, "": true}, 1.5, "", "(x)"]`))
+
+	out := sexp.Print(ast)
+	assert.Equal(t, `(if (== (a) "1") "a b" {"" true z null} 1.5 "" "(x)")`, out)
+
+	back, err := sexp.Parse([]byte(out))
+	assert.Nil(t, err)
+	assert.Equal(t, ast, back)
+}
+
+func TestRun(t *testing.T) {
+	ast, _ := sexp.Parse([]byte(`(+ 1 (* 2 3))`))
+
+	out := gisp.Run(&gisp.Context{
+		AST: ast,
+		Sandbox: gisp.New(gisp.Box{
+			"+": lib.Add,
+			"*": lib.Multiply,
+		}),
+	})
+
+	assert.Equal(t, float64(7), out)
+}
+
+func TestPrintDict(t *testing.T) {
+	d := gisp.NewDict()
+	d.Set("b", 1.0)
+	d.Set("a c", 2.0)
+
+	assert.Equal(t, `($ {b 1 "a c" 2})`, sexp.Print([]interface{}{"$", d}))
+}
@@ -1,22 +1,40 @@
 package gisp
 
-import "github.com/a8m/djson"
-
 // RunJSON json entrance
 func RunJSON(code string, ctx *Context) (interface{}, error) {
 	return RunJSONRaw([]byte(code), ctx)
 }
 
-// RunJSONRaw json entrance
-func RunJSONRaw(code []byte, ctx *Context) (ret interface{}, err error) {
-	ast, err := djson.Decode(code)
-	ctx.AST = ast
-	ret = Run(ctx)
-	return
+// RunJSONRaw json entrance, it decodes the code with DJSON
+func RunJSONRaw(code []byte, ctx *Context) (interface{}, error) {
+	return RunDecode(DJSON, code, ctx)
+}
+
+// RunBinary entrance for the AST encoded by msgpack.Encode
+func RunBinary(code []byte, ctx *Context) (interface{}, error) {
+	return RunDecode(MsgPack, code, ctx)
+}
+
+// RunJSON5 entrance for the json that has comments, trailing commas,
+// single-quoted strings or unquoted keys
+func RunJSON5(code string, ctx *Context) (interface{}, error) {
+	return RunJSON5Raw([]byte(code), ctx)
+}
+
+// RunJSON5Raw json5 entrance
+func RunJSON5Raw(code []byte, ctx *Context) (interface{}, error) {
+	return RunDecode(JSON5, code, ctx)
 }
 
-// Arg sugar
+// Arg sugar, if the Args is set the arguments are returned without evaluation
 func (ctx *Context) Arg(index int) interface{} {
+	if ctx.Args != nil && index > 0 {
+		if index > len(ctx.Args) {
+			return nil
+		}
+		return ctx.Args[index-1]
+	}
+
 	ast := ctx.AST.([]interface{})
 
 	if index >= len(ast) {
@@ -35,9 +53,18 @@ func (ctx *Context) Arg(index int) interface{} {
 	})
 }
 
-// ArgNum Get argument as number
+// ArgNum Get argument as number, the exact numbers will be converted to float64
 func (ctx *Context) ArgNum(index int) float64 {
-	return ctx.Arg(index).(float64)
+	arg := ctx.Arg(index)
+
+	switch arg.(type) {
+	case int64:
+		return float64(arg.(int64))
+	case Decimal:
+		return arg.(Decimal).Float64()
+	default:
+		return arg.(float64)
+	}
 }
 
 // ArgStr Get argument as string
@@ -50,9 +77,23 @@ func (ctx *Context) ArgBool(index int) bool {
 	return ctx.Arg(index).(bool)
 }
 
-// ArgObj Get argument as object
+// ArgObj Get argument as object, a *Dict is converted to a new map,
+// use ArgDict to change the dict in place
 func (ctx *Context) ArgObj(index int) map[string]interface{} {
-	return ctx.Arg(index).(map[string]interface{})
+	arg := ctx.Arg(index)
+	if dict, ok := arg.(*Dict); ok {
+		return dict.Map()
+	}
+	return arg.(map[string]interface{})
+}
+
+// ArgDict Get argument as *Dict, a map is converted to a new dict with sorted keys
+func (ctx *Context) ArgDict(index int) *Dict {
+	arg := ctx.Arg(index)
+	if m, ok := arg.(map[string]interface{}); ok {
+		return DictFromMap(m)
+	}
+	return arg.(*Dict)
 }
 
 // ArgArr Get argument as array
@@ -3,8 +3,11 @@ package gisp_test
 import (
 	"testing"
 
+	"github.com/a8m/djson"
 	"github.com/stretchr/testify/assert"
 	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+	"github.com/ysmood/gisp/msgpack"
 )
 
 func TestJSON(t *testing.T) {
@@ -56,3 +59,78 @@ func TestTypes(t *testing.T) {
 		"ok",
 	}, out)
 }
+
+func TestJSON5(t *testing.T) {
+	out, err := gisp.RunJSON5(`
+		// sum
+		['+', 1, {a: 2}['a'],]
+	`, &gisp.Context{
+		Sandbox: gisp.New(gisp.Box{}),
+	})
+	assert.NotNil(t, err)
+	assert.Nil(t, out)
+
+	out, err = gisp.RunJSON5(`
+		// sum
+		['+', 1, 2,]
+	`, &gisp.Context{
+		Sandbox: gisp.New(gisp.Box{
+			"+": func(ctx *gisp.Context) interface{} {
+				return ctx.ArgNum(1) + ctx.ArgNum(2)
+			},
+		}),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3), out)
+}
+
+func TestArgDict(t *testing.T) {
+	sandbox := gisp.New(gisp.Box{
+		":": func(ctx *gisp.Context) interface{} {
+			d := gisp.NewDict()
+			d.Set("b", ctx.Arg(1))
+			d.Set("a", ctx.Arg(2))
+			return d
+		},
+		"obj": func(ctx *gisp.Context) interface{} {
+			return ctx.ArgObj(1)
+		},
+		"dict": func(ctx *gisp.Context) interface{} {
+			return ctx.ArgDict(1).Keys()
+		},
+	})
+
+	run := func(code string) interface{} {
+		out, _ := gisp.RunJSON(code, &gisp.Context{Sandbox: sandbox})
+		return out
+	}
+
+	assert.Equal(t, map[string]interface{}{"b": 1.0, "a": 2.0}, run(`["obj", [":", 1, 2]]`))
+	assert.Equal(t, []string{"b", "a"}, run(`["dict", [":", 1, 2]]`))
+	assert.Equal(t, []string{"a", "b"}, run(`["dict", {"b": 1, "a": 2}]`))
+}
+
+func TestRunJSONError(t *testing.T) {
+	ctx := &gisp.Context{Sandbox: gisp.New(nil)}
+	out, err := gisp.RunJSON(`["+", 1`, ctx)
+	assert.NotNil(t, err)
+	assert.Nil(t, out)
+	assert.Nil(t, ctx.AST)
+}
+
+func TestRunBinary(t *testing.T) {
+	ast, _ := djson.Decode([]byte(`["+", 1.5, ["+", 1, 1]]`))
+	code, _ := msgpack.Encode(ast)
+
+	out, err := gisp.RunBinary(code, &gisp.Context{
+		Sandbox: gisp.New(gisp.Box{
+			"+": lib.Add,
+		}),
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3.5, out)
+
+	_, err = gisp.RunBinary([]byte{0xc1}, &gisp.Context{})
+	assert.EqualError(t, err, "unsupported type 0xc1 at 0")
+}
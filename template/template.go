@@ -0,0 +1,420 @@
+// Package template renders text documents that embed gisp expressions.
+//
+// Example:
+//
+//	<ul>
+//	{{for i user users}}
+//	  <li>{{user.name}}{{if (get (user) "admin" false)}} (admin){{end}}</li>
+//	{{end}}
+//	</ul>
+//
+// An expression is a json AST such as ["+", 1, 2] or 1.5, a S-expression such as (+ 1 2),
+// or a path such as user.name that reads the value like lib.Get.
+// The tags are:
+//
+//	{{exp}}                      outputs the value, the html is escaped
+//	{{raw exp}}                  outputs the value without escaping
+//	{{for key value exp}} {{end}}  runs the body for each item, the same as lib.For
+//	{{if exp}} {{else if exp}} {{else}} {{end}}  the same as lib.If, the condition must be bool
+//	{{; comment}}                outputs nothing
+//
+// The blocks are compiled into the gisp AST that uses lib.For, lib.If and lib.Do directly,
+// so they work without the names in the sandbox.
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/internal/pos"
+	"github.com/ysmood/gisp/lib"
+	"github.com/ysmood/gisp/sexp"
+)
+
+// Error parse error with position info
+type Error = pos.Error
+
+// Parser the options to parse a template
+type Parser struct {
+	// Left and Right are the delimiters of the tags, "{{" and "}}" by default
+	Left, Right string
+
+	// Text disables the html escaping
+	Text bool
+}
+
+// Parse parses the html template with the default options
+func Parse(text string) (*Template, error) {
+	return Parser{}.Parse(text)
+}
+
+// Template the compiled template, it's safe to render it concurrently
+type Template struct {
+	ast node
+}
+
+// Render renders the template against the sandbox, the names defined by the
+// template don't leak into the sandbox
+func (t *Template) Render(sandbox *gisp.Sandbox) (string, error) {
+	return t.Run(&gisp.Context{Sandbox: sandbox})
+}
+
+// Run renders the template with the ENV and hooks of the ctx, the panic is returned as the error
+func (t *Template) Run(ctx *gisp.Context) (ret string, err error) {
+	out := newOutput()
+
+	c := *ctx
+	c.AST = t.ast(out)
+	c.Args = nil
+	c.Sandbox = ctx.Sandbox.Create()
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch v := r.(type) {
+			case gisp.Error:
+				err = v
+			case error:
+				err = v
+			default:
+				err = fmt.Errorf("%v", v)
+			}
+		}
+	}()
+
+	gisp.Run(&c)
+	return out.String(), nil
+}
+
+// node builds the AST of a part of the template, the writes in it are bound to the out.
+// The buffer of a render is only reachable from the Go closures, not from the sandbox.
+type node func(out *output) interface{}
+
+// output the buffer of a render
+type output struct {
+	strings.Builder
+
+	// write outputs the escaped value
+	write func(ctx *gisp.Context) interface{}
+
+	// writeRaw outputs the value as it is
+	writeRaw func(ctx *gisp.Context) interface{}
+}
+
+func newOutput() *output {
+	out := &output{}
+	out.write = func(ctx *gisp.Context) interface{} {
+		out.emit(ctx, html.EscapeString(text(ctx.Arg(1))))
+		return nil
+	}
+	out.writeRaw = func(ctx *gisp.Context) interface{} {
+		out.emit(ctx, text(ctx.Arg(1)))
+		return nil
+	}
+	return out
+}
+
+func (out *output) emit(ctx *gisp.Context, s string) {
+	if out.Len()+len(s) > lib.MaxStringLen {
+		ctx.Error(fmt.Sprintf("max string length exceeded %v", lib.MaxStringLen))
+	}
+	out.WriteString(s)
+}
+
+// writeNode outputs the value of the exp, escaped or not
+func writeNode(raw bool, exp interface{}) node {
+	return func(out *output) interface{} {
+		if raw {
+			return []interface{}{out.writeRaw, exp}
+		}
+		return []interface{}{out.write, exp}
+	}
+}
+
+// text converts the value to the output string, nil is empty, arrays and dicts are json
+func text(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case gisp.Decimal:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		return fmt.Sprint(val)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Parse parses the template
+func (p Parser) Parse(text string) (*Template, error) {
+	if p.Left == "" {
+		p.Left = "{{"
+	}
+	if p.Right == "" {
+		p.Right = "}}"
+	}
+
+	c := &compiler{Parser: p, src: text}
+	ast, err := c.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &Template{ast: ast}, nil
+}
+
+type compiler struct {
+	Parser
+	src string
+	pos int
+
+	stack []*block
+}
+
+// block is a for, if or the root, the parts of the current branch are in the last body
+type block struct {
+	keyword string
+	pos     int
+	args    []interface{} // the key, value and collection of for, or the conditions of if
+	bodies  [][]node
+	hasElse bool
+}
+
+func (b *block) add(part node) {
+	last := len(b.bodies) - 1
+	b.bodies[last] = append(b.bodies[last], part)
+}
+
+func do(out *output, parts []node) interface{} {
+	ast := make([]interface{}, 0, len(parts)+1)
+	ast = append(ast, lib.Do)
+	for _, part := range parts {
+		ast = append(ast, part(out))
+	}
+	return ast
+}
+
+// ast builds the AST of the block
+func (b *block) ast(out *output) interface{} {
+	switch b.keyword {
+	case "for":
+		return []interface{}{lib.For, b.args[0], b.args[1], b.args[2], do(out, b.bodies[0])}
+	case "if":
+		var ast interface{}
+		if b.hasElse {
+			ast = do(out, b.bodies[len(b.bodies)-1])
+		}
+		for i := len(b.args) - 1; i >= 0; i-- {
+			ast = []interface{}{lib.If, b.args[i], do(out, b.bodies[i]), ast}
+		}
+		return ast
+	default:
+		return do(out, b.bodies[0])
+	}
+}
+
+func (c *compiler) error(offset int, msg string) error {
+	return pos.New(c.src, offset, msg)
+}
+
+func (c *compiler) compile() (node, error) {
+	root := &block{bodies: [][]node{{}}}
+	c.stack = []*block{root}
+
+	for c.pos < len(c.src) {
+		start := strings.Index(c.src[c.pos:], c.Left)
+		if start < 0 {
+			c.text(c.src[c.pos:])
+			break
+		}
+		start += c.pos
+		c.text(c.src[c.pos:start])
+
+		end := c.tagEnd(start + len(c.Left))
+		if end < 0 {
+			return nil, c.error(start, "missing "+strconv.Quote(c.Right))
+		}
+
+		if err := c.tag(start, strings.TrimSpace(c.src[start+len(c.Left):end])); err != nil {
+			return nil, err
+		}
+		c.pos = end + len(c.Right)
+	}
+
+	if len(c.stack) > 1 {
+		b := c.stack[len(c.stack)-1]
+		return nil, c.error(b.pos, "missing the end of "+b.keyword)
+	}
+	return root.ast, nil
+}
+
+func (c *compiler) current() *block {
+	return c.stack[len(c.stack)-1]
+}
+
+func (c *compiler) text(s string) {
+	if s != "" {
+		c.current().add(writeNode(true, s))
+	}
+}
+
+// tagEnd returns the index of the right delimiter, the ones in brackets or strings are skipped
+func (c *compiler) tagEnd(from int) int {
+	depth := 0
+	for i := from; i < len(c.src); i++ {
+		if depth <= 0 && strings.HasPrefix(c.src[i:], c.Right) {
+			return i
+		}
+
+		switch c.src[i] {
+		case '"':
+			for i++; i < len(c.src) && c.src[i] != '"'; i++ {
+				if c.src[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+	}
+	return -1
+}
+
+func (c *compiler) tag(pos int, content string) error {
+	keyword, rest := content, ""
+	if i := strings.IndexFunc(content, unicode.IsSpace); i >= 0 {
+		keyword, rest = content[:i], strings.TrimSpace(content[i:])
+	}
+
+	b := c.current()
+
+	switch {
+	case strings.HasPrefix(content, ";"):
+		return nil
+
+	case keyword == "end" && rest == "":
+		if len(c.stack) == 1 {
+			return c.error(pos, "unexpected end")
+		}
+		c.stack = c.stack[:len(c.stack)-1]
+		c.current().add(b.ast)
+		return nil
+
+	case keyword == "else":
+		if b.keyword != "if" || b.hasElse {
+			return c.error(pos, "unexpected else")
+		}
+		if rest == "" {
+			b.hasElse = true
+			b.bodies = append(b.bodies, []node{})
+			return nil
+		}
+		if !strings.HasPrefix(rest, "if ") {
+			return c.error(pos, "unexpected "+strconv.Quote(rest))
+		}
+		cond, err := c.expression(pos, strings.TrimSpace(rest[3:]))
+		if err != nil {
+			return err
+		}
+		b.args = append(b.args, cond)
+		b.bodies = append(b.bodies, []node{})
+		return nil
+
+	case keyword == "if" && rest != "":
+		cond, err := c.expression(pos, rest)
+		if err != nil {
+			return err
+		}
+		c.stack = append(c.stack, &block{keyword: "if", pos: pos, args: []interface{}{cond}, bodies: [][]node{{}}})
+		return nil
+
+	case keyword == "for" && rest != "":
+		list := strings.Fields(rest)
+		if len(list) < 3 {
+			return c.error(pos, "for expects a key name, a value name and a collection")
+		}
+		coll := strings.TrimSpace(rest[len(list[0]):])
+		coll = strings.TrimSpace(coll[len(list[1]):])
+		exp, err := c.expression(pos, coll)
+		if err != nil {
+			return err
+		}
+		c.stack = append(c.stack, &block{
+			keyword: "for", pos: pos, args: []interface{}{list[0], list[1], exp}, bodies: [][]node{{}},
+		})
+		return nil
+
+	case keyword == "raw" && rest != "":
+		exp, err := c.expression(pos, rest)
+		if err != nil {
+			return err
+		}
+		b.add(writeNode(true, exp))
+		return nil
+
+	default:
+		exp, err := c.expression(pos, content)
+		if err != nil {
+			return err
+		}
+		b.add(writeNode(c.Text, exp))
+		return nil
+	}
+}
+
+// expression parses the json, S-expression or path, the bare numbers, true, false and null are json
+func (c *compiler) expression(pos int, src string) (interface{}, error) {
+	if src == "" {
+		return nil, c.error(pos, "missing expression")
+	}
+
+	var ast interface{}
+	var err error
+
+	switch src[0] {
+	case '(':
+		ast, err = sexp.Parse([]byte(src))
+	case '[', '{', '"':
+		ast, err = gisp.DJSON.Decode([]byte(src))
+	default:
+		if _, e := strconv.ParseFloat(src, 64); e == nil || src == "true" || src == "false" || src == "null" {
+			ast, err = gisp.DJSON.Decode([]byte(src))
+			break
+		}
+		if strings.ContainsFunc(src, unicode.IsSpace) {
+			return nil, c.error(pos, "invalid expression "+strconv.Quote(src))
+		}
+		ast = path(src)
+	}
+
+	if err != nil {
+		return nil, c.error(pos, "invalid expression: "+err.Error())
+	}
+	return ast, nil
+}
+
+// path converts the path such as "user.name" to the AST that reads it
+func path(src string) interface{} {
+	list := strings.SplitN(src, ".", 2)
+	if len(list) == 1 {
+		return []interface{}{src}
+	}
+	return []interface{}{lib.Get, []interface{}{list[0]}, list[1]}
+}
@@ -0,0 +1,127 @@
+package template_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gisp"
+	"github.com/ysmood/gisp/lib"
+	"github.com/ysmood/gisp/template"
+)
+
+func render(t *testing.T, src string, data gisp.Box) string {
+	tpl, err := template.Parse(src)
+	assert.Nil(t, err)
+
+	box := lib.Std()
+	for k, v := range data {
+		box[k] = v
+	}
+
+	out, err := tpl.Render(gisp.New(box))
+	assert.Nil(t, err)
+	return out
+}
+
+func TestRender(t *testing.T) {
+	assert.Equal(t, "Hi Ann, 3", render(t, `Hi {{user.name}}, {{["+", 1, 2]}}`, gisp.Box{
+		"user": map[string]interface{}{"name": "Ann"},
+	}))
+	assert.Equal(t, "3 a {&#34;a&#34;:[1]}", render(t, `{{(+ 1 2)}} {{"a"}} {{{"a": [1]}}}`, nil))
+	assert.Equal(t, "plain", render(t, "plain", nil))
+	assert.Equal(t, "", render(t, "", nil))
+	assert.Equal(t, "a b", render(t, "a{{; note }} b", nil))
+}
+
+func TestEscape(t *testing.T) {
+	data := gisp.Box{"s": `<a href="x">`}
+
+	assert.Equal(t, "&lt;a href=&#34;x&#34;&gt; <a href=\"x\">", render(t, "{{s}} {{raw s}}", data))
+
+	tpl, err := template.Parser{Text: true}.Parse("{{s}}")
+	assert.Nil(t, err)
+	out, _ := tpl.Render(gisp.New(data))
+	assert.Equal(t, `<a href="x">`, out)
+}
+
+func TestFor(t *testing.T) {
+	data := gisp.Box{"users": []interface{}{
+		map[string]interface{}{"name": "a", "admin": true},
+		map[string]interface{}{"name": "<b>", "admin": false},
+	}}
+
+	assert.Equal(t, "<li>0 a (admin)</li><li>1 &lt;b&gt;</li>", render(t,
+		`{{for i u users}}<li>{{i}} {{u.name}}{{if (get (u) "admin")}} (admin){{end}}</li>{{end}}`, data,
+	))
+	assert.Equal(t, "a=1;b=2;", render(t, `{{for k v ["$", {"b": 2, "a": 1}]}}{{k}}={{v}};{{end}}`, nil))
+}
+
+func TestIf(t *testing.T) {
+	src := `{{if (< (n) 0)}}neg{{else if ["==", ["n"], 0]}}zero{{else}}pos{{end}}`
+
+	assert.Equal(t, "neg", render(t, src, gisp.Box{"n": -1}))
+	assert.Equal(t, "zero", render(t, src, gisp.Box{"n": 0}))
+	assert.Equal(t, "pos", render(t, src, gisp.Box{"n": 1}))
+	assert.Equal(t, "", render(t, `{{if false}}x{{end}}`, nil))
+	assert.Equal(t, "1.5 true", render(t, `{{1.5}} {{true}}{{null}}`, nil))
+}
+
+func TestScope(t *testing.T) {
+	sandbox := gisp.New(lib.Std())
+
+	tpl, _ := template.Parse(`{{raw (def "x" 1)}}{{x}}`)
+	out, err := tpl.Render(sandbox)
+	assert.Nil(t, err)
+	assert.Equal(t, "11", out)
+
+	_, has := sandbox.Get("x")
+	assert.False(t, has)
+
+	// the output buffer is not a name in the sandbox
+	tpl, _ = template.Parse(`a{{raw (def "template.output" 1)}}b{{(template.output)}}`)
+	out, err = tpl.Render(sandbox)
+	assert.Nil(t, err)
+	assert.Equal(t, "a1b1", out)
+}
+
+func TestDelims(t *testing.T) {
+	tpl, err := template.Parser{Left: "<%", Right: "%>"}.Parse(`{{x}} <% ["+", "a", "b"] %>`)
+	assert.Nil(t, err)
+	out, _ := tpl.Render(gisp.New(lib.Std()))
+	assert.Equal(t, "{{x}} ab", out)
+}
+
+func TestParseError(t *testing.T) {
+	check := func(src, msg string) {
+		_, err := template.Parse(src)
+		assert.EqualError(t, err, msg)
+	}
+
+	check("a\n {{x", `2:2: missing "}}"`)
+	check("{{for i v list}}", "1:1: missing the end of for")
+	check("{{end}}", "1:1: unexpected end")
+	check("{{else}}", "1:1: unexpected else")
+	check("{{if true}}{{else}}{{else}}{{end}}", "1:20: unexpected else")
+	check("{{for i list}}{{end}}", "1:1: for expects a key name, a value name and a collection")
+	check("{{a b}}", `1:1: invalid expression "a b"`)
+	check("{{(a}}", `1:1: missing "}}"`)
+	check("{{ [1,] }}", "1:1: invalid expression: invalid character ']' looking for beginning of value")
+}
+
+func TestRunError(t *testing.T) {
+	tpl, _ := template.Parse(`{{if 1}}x{{end}}`)
+	_, err := tpl.Render(gisp.New(lib.Std()))
+	assert.NotNil(t, err)
+
+	tpl, _ = template.Parse(`{{(throw "err")}}`)
+	_, err = tpl.Run(&gisp.Context{Sandbox: gisp.New(lib.Std())})
+	assert.EqualError(t, err, "err")
+
+	old := lib.MaxStringLen
+	lib.MaxStringLen = 3
+	defer func() { lib.MaxStringLen = old }()
+
+	tpl, _ = template.Parse(`{{for i v ["$", [1, 2, 3]]}}ab{{end}}`)
+	_, err = tpl.Render(gisp.New(lib.Std()))
+	assert.EqualError(t, err, "max string length exceeded 3")
+}